@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// The groups of the agenda, in the order they are shown.
+const (
+	overdueGroup = iota
+	todayGroup
+	tomorrowGroup
+	weekGroup
+	laterGroup
+)
+
+var groupNames = []string{"Overdue", "Today", "Tomorrow", "This week", "Later"}
+
+// agenda keeps the cursor on the entry id while the entries move between
+// the groups, row is only used once it is gone. back is where the lists
+// were when the agenda was opened.
+type agenda struct {
+	id   int
+	row  int
+	top  int
+	back listCursor
+}
+
+// agendaEntry points to an open entry by the index of its list and its row
+// in that list.
+type agendaEntry struct {
+	list  int
+	row   int
+	group int
+}
+
+func (ui *UI) openAgenda() {
+	ui.view = agendaMode
+	ui.mode = agendaMode
+	ui.agenda = agenda{id: -1, back: ui.saveCursor()}
+	ui.syncAgenda()
+}
+
+// closeAgenda goes back to the list and entry the agenda was opened from.
+func (ui *UI) closeAgenda() {
+	ui.restoreCursor(ui.agenda.back)
+	ui.leaveAgenda()
+}
+
+func (ui *UI) leaveAgenda() {
+	ui.view = normalMode
+	ui.mode = normalMode
+	ui.calculateWindow()
+}
+
+// agendaEntries returns the open entries of all lists by due date. Entries
+// without one come last.
+func (ui *UI) agendaEntries() []agendaEntry {
+	today := startOfDay(time.Now())
+	var entries []agendaEntry
+	for i := range ui.lists {
+		if ui.lists[i].smart() {
+			continue
+		}
+		for row, item := range ui.lists[i].items {
+			if !item.done {
+				entries = append(entries, agendaEntry{i, row, dueGroup(item.due, today)})
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := ui.agendaItem(entries[i]), ui.agendaItem(entries[j])
+		if a.due == "" || b.due == "" {
+			return a.due != "" && b.due == ""
+		}
+		return a.due < b.due
+	})
+	return entries
+}
+
+func (ui *UI) agendaItem(e agendaEntry) *Item {
+	return &ui.lists[e.list].items[e.row]
+}
+
+// dueGroup returns the agenda group of a due date, entries without a valid
+// one are later.
+func dueGroup(due string, today time.Time) int {
+	t, err := parseDate(due)
+	if err != nil {
+		return laterGroup
+	}
+	days := daysBetween(today, t)
+	switch {
+	case days < 0:
+		return overdueGroup
+	case days == 0:
+		return todayGroup
+	case days == 1:
+		return tomorrowGroup
+	case days < 7-weekday(today):
+		return weekGroup
+	}
+	return laterGroup
+}
+
+// weekday counts the days from monday on.
+func weekday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// daysBetween counts the days between two starts of days, which are not
+// always 24 hours apart.
+func daysBetween(from, to time.Time) int {
+	return int(math.Round(to.Sub(from).Hours() / 24))
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// parseDate returns the day of a stored due date.
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(dateFormat, s, time.Local)
+}
+
+// syncAgenda makes the entry under the agenda cursor the current entry of
+// its list, so editing and commands work on it.
+func (ui *UI) syncAgenda() {
+	entries := ui.agendaEntries()
+	for i, e := range entries {
+		if ui.agendaItem(e).id == ui.agenda.id {
+			ui.agenda.row = i
+		}
+	}
+	ui.agenda.row = max(min(ui.agenda.row, len(entries)-1), 0)
+	if len(entries) == 0 {
+		return
+	}
+	e := entries[ui.agenda.row]
+	ui.agenda.id = ui.agendaItem(e).id
+	ui.current = e.list
+	ui.lists[e.list].row = e.row
+}
+
+func (ui *UI) agendaDown() {
+	ui.moveAgenda(1)
+}
+
+func (ui *UI) agendaUp() {
+	ui.moveAgenda(-1)
+}
+
+func (ui *UI) moveAgenda(n int) {
+	entries := ui.agendaEntries()
+	if row := ui.agenda.row + n; row >= 0 && row < len(entries) {
+		ui.agenda.row = row
+		ui.agenda.id = ui.agendaItem(entries[row]).id
+	}
+	ui.syncAgenda()
+}
+
+func (ui *UI) agendaToggle() {
+	entries := ui.agendaEntries()
+	if len(entries) == 0 {
+		return
+	}
+	item := ui.agendaItem(entries[ui.agenda.row])
+	ids := []int{item.id}
+	text := item.content
+	err := ui.change("mark done", ids, nil, func(step *undoStep) error {
+		return ui.db.updateItems(ids, "done", true)
+	})
+	if err != nil {
+		ui.fail(err)
+		return
+	}
+	ui.syncAgenda()
+	ui.notify("Done: " + text)
+}
+
+// postpone moves the due date of the entry under the cursor by days, an
+// entry without one becomes due today first.
+func (ui *UI) postpone(days int) {
+	entries := ui.agendaEntries()
+	if len(entries) == 0 {
+		return
+	}
+	item := ui.agendaItem(entries[ui.agenda.row])
+	day := startOfDay(time.Now())
+	if t, err := parseDate(item.due); err == nil {
+		day = t.AddDate(0, 0, days)
+	}
+	if err := ui.setDue([]int{item.id}, day.Format(dateFormat)); err != nil {
+		ui.fail(err)
+	}
+}
+
+func (ui *UI) agendaLater() {
+	ui.postpone(1)
+}
+
+func (ui *UI) agendaEarlier() {
+	ui.postpone(-1)
+}
+
+func (ui *UI) promptDue() {
+	if l := ui.currentList(); l != nil && l.hasCurrent() {
+		ui.promptCommand("due " + l.currentItem().due)
+	}
+}
+
+// agendaOpenList leaves the agenda for the list of the entry under the
+// cursor.
+func (ui *UI) agendaOpenList() {
+	ui.syncAgenda()
+	ui.leaveAgenda()
+}
+
+func renderAgenda(ui *UI) {
+	width := ui.listWidth() - 2*leftOffset
+	drawClipped(ui, leftOffset, 1, width, padChunk("Agenda"), lightDark)
+	entries := ui.agendaEntries()
+	if len(entries) == 0 {
+		ui.renderLine("There are no open entries", headerHeight-4)
+		return
+	}
+
+	// Every group starts with a heading line, cursor is the line of the
+	// entry under the cursor.
+	type agendaLine struct {
+		heading string
+		entry   int
+	}
+	var lines []agendaLine
+	var cursor int
+	for i, e := range entries {
+		if i == 0 || entries[i-1].group != e.group {
+			var n int
+			for _, other := range entries[i:] {
+				if other.group == e.group {
+					n++
+				}
+			}
+			lines = append(lines, agendaLine{heading: fmt.Sprintf("%s (%d)", groupNames[e.group], n)})
+		}
+		if i == ui.agenda.row {
+			cursor = len(lines)
+		}
+		lines = append(lines, agendaLine{entry: i})
+	}
+
+	top := 3
+	space := max(ui.height()-top-bottomOffset-footerHeight, 1)
+	if cursor < ui.agenda.top+1 {
+		ui.agenda.top = max(cursor-1, 0)
+	} else if cursor >= ui.agenda.top+space {
+		ui.agenda.top = cursor - space + 1
+	}
+	for i := ui.agenda.top; i < len(lines) && i < ui.agenda.top+space; i++ {
+		y := top + i - ui.agenda.top
+		line := lines[i]
+		if line.heading != "" {
+			drawClipped(ui, leftOffset, y, width, separator(ui, padChunk(line.heading), ui.width()-width-2), primaryLight)
+			continue
+		}
+		e := entries[line.entry]
+		item := ui.agendaItem(e)
+		style := darkLight
+		if line.entry == ui.agenda.row && ui.mode != editMode {
+			style = primaryLight
+		}
+		right := ui.lists[e.list].name
+		if e.group == overdueGroup || e.group == weekGroup || e.group == laterGroup && item.due != "" {
+			right = item.due + "  " + right
+		}
+		rightWidth := min(textWidth(right), width/3)
+		drawClipped(ui, leftOffset+width-rightWidth, y, rightWidth, right, darkSecondary)
+		drawClipped(ui, leftOffset, y, 4, "[ ] ", darkLight)
+		available := width - rightWidth - 5
+		if line.entry == ui.agenda.row && ui.mode == editMode {
+			ui.editor.render(ui, leftOffset+4, y, available, darkLight)
+			continue
+		}
+		w := drawClipped(ui, leftOffset+4, y, available, item.content, style)
+		drawClipped(ui, leftOffset+4+w, y, available-w, item.extras(), darkSecondary)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloseAgendaGoesBack(t *testing.T) {
+	ui := newTestUI(t)
+	first, second := mustList(t, ui.db), mustList(t, ui.db)
+	mustItem(t, ui.db, first)
+	current := mustItem(t, ui.db, first)
+	due := mustItem(t, ui.db, second)
+	if err := ui.db.updateItems([]int{due}, "due", time.Now().Format(dateFormat)); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	ui.lists[0].row = indexOf(&ui.lists[0], current)
+
+	ui.openAgenda()
+	if ui.current != 1 {
+		t.Fatalf("agenda starts on list %d, want the list of the due entry", ui.current)
+	}
+	ui.closeAgenda()
+	if ui.current != 0 || ui.currentList().currentItem().id != current {
+		t.Errorf("after closing the agenda: list %d, row %d, want the entry it was opened on", ui.current, ui.currentList().row)
+	}
+
+	ui.openAgenda()
+	ui.agendaOpenList()
+	if ui.current != 1 || ui.currentList().currentItem().id != due {
+		t.Errorf("opening the list of the entry: list %d, row %d, want the due entry", ui.current, ui.currentList().row)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const backupInterval = 24 * time.Hour
+
+// Snapshots are named by the time to the millisecond, a safety snapshot and
+// a backup taken within the same second still get different names. Older
+// snapshots were named to the second.
+const backupTimeFormat = "20060102-150405.000"
+const oldBackupTimeFormat = "20060102-150405"
+
+// The columns a snapshot needs to be usable, newer columns are added by
+// DB.init after a restore.
+var requiredColumns = map[string][]string{
+	"ui":   {"id", "list_order"},
+	"list": {"id", "name", "item_order"},
+	"item": {"id", "content", "done", "list_id"},
+}
+
+func backupDir() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	dir = path.Join(dir, "backups")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// snapshots returns the file names of all snapshots, oldest first.
+func snapshots() ([]string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, ok := snapshotTime(e.Name()); ok && !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		a, _ := snapshotTime(names[i])
+		b, _ := snapshotTime(names[j])
+		return a.Before(b)
+	})
+	return names, nil
+}
+
+func snapshotTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, "data-") || !strings.HasSuffix(name, ".db") {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, "data-"), ".db")
+	for _, format := range []string{backupTimeFormat, oldBackupTimeFormat} {
+		if t, err := time.ParseInLocation(format, stamp, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// autoBackup takes a snapshot if the newest one is older than a day and
+// keeps only the newest retention snapshots. A retention of 0 disables it.
+func autoBackup(db *DB, retention int) error {
+	if retention <= 0 {
+		return nil
+	}
+	names, err := snapshots()
+	if err != nil {
+		return err
+	}
+	if len(names) != 0 {
+		newest, _ := snapshotTime(names[len(names)-1])
+		if time.Since(newest) < backupInterval {
+			return nil
+		}
+	}
+	if _, err := db.snapshot(); err != nil {
+		return err
+	}
+	return pruneSnapshots(retention)
+}
+
+func pruneSnapshots(retention int) error {
+	names, err := snapshots()
+	if err != nil {
+		return err
+	}
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	for len(names) > retention {
+		if err := os.Remove(path.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// replaceSnapshots deletes all snapshots after taking a new one and returns
+// how many were deleted. With no snapshots it does nothing.
+func (db *DB) replaceSnapshots() (int, error) {
+	names, err := snapshots()
+	if err != nil || len(names) == 0 {
+		return 0, err
+	}
+	if _, err := db.snapshot(); err != nil {
+		return 0, err
+	}
+	dir, err := backupDir()
+	if err != nil {
+		return 0, err
+	}
+	for i, name := range names {
+		if err := os.Remove(path.Join(dir, name)); err != nil {
+			return i, err
+		}
+	}
+	return len(names), nil
+}
+
+// snapshot writes a copy of the database into the backup directory and
+// returns its path.
+func (db *DB) snapshot() (string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return "", err
+	}
+	// A name that is taken moves on by a millisecond.
+	t := time.Now()
+	file := path.Join(dir, "data-"+t.Format(backupTimeFormat)+".db")
+	for _, err := os.Stat(file); err == nil; _, err = os.Stat(file) {
+		t = t.Add(time.Millisecond)
+		file = path.Join(dir, "data-"+t.Format(backupTimeFormat)+".db")
+	}
+	dst, err := sql.Open("sqlite3", file)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+	if err := copyDatabase(dst, db.db); err != nil {
+		os.Remove(file)
+		return "", err
+	}
+	// The copy inherits WAL mode, a rollback journal keeps the snapshot a
+	// single self-contained file.
+	if _, err := dst.Exec("PRAGMA journal_mode = DELETE"); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// restore replaces the content of the database with the snapshot. Running
+// instances still know the versions from before, so the restored versions
+// are moved past all of them: their next write is a conflict instead of
+// silently overwriting the restored data.
+func (db *DB) restore(file string) error {
+	if err := validateSnapshot(file); err != nil {
+		return err
+	}
+	highest, err := db.highestVersion()
+	if err != nil {
+		return err
+	}
+	src, err := sql.Open("sqlite3", "file:"+file+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := copyDatabase(db.db, src); err != nil {
+		return err
+	}
+	if err := db.init(); err != nil {
+		return err
+	}
+	return db.bumpVersions(highest + 1)
+}
+
+// highestVersion returns the highest version or order version of any row.
+func (db *DB) highestVersion() (int, error) {
+	var highest int
+	row := db.db.QueryRow(`SELECT COALESCE(MAX(v), 0) FROM (
+		SELECT MAX(version) AS v FROM item
+		UNION ALL SELECT MAX(version) FROM list
+		UNION ALL SELECT MAX(order_version) FROM list
+		UNION ALL SELECT MAX(version) FROM ui)`)
+	return highest, row.Scan(&highest)
+}
+
+func (db *DB) bumpVersions(by int) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("UPDATE item SET version = version + ?", by); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE list SET version = version + ?, order_version = order_version + ?", by, by); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE ui SET version = version + ?", by); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyDatabase copies src into dst with the SQLite online backup API.
+func copyDatabase(dst, src *sql.DB) error {
+	ctx := context.Background()
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			b, err := dstDriver.(*sqlite3.SQLiteConn).Backup("main", srcDriver.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Close()
+				return err
+			}
+			return b.Close()
+		})
+	})
+}
+
+func validateSnapshot(file string) error {
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", "file:"+file+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%s is not a valid database: %w", file, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s is corrupted: %s", file, result)
+	}
+	for table, columns := range requiredColumns {
+		found, err := tableColumns(db, table)
+		if err != nil {
+			return err
+		}
+		for _, c := range columns {
+			if !found[c] {
+				return fmt.Errorf("%s is not a todo database: table %s lacks column %s", file, table, c)
+			}
+		}
+	}
+	return nil
+}
+
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+func runBackup(args []string) error {
+	db, err := newDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.close()
+	if err := db.init(); err != nil {
+		return err
+	}
+	file, err := db.snapshot()
+	if err != nil {
+		return err
+	}
+	fmt.Println("created", file)
+	return nil
+}
+
+func runRestore(args []string) error {
+	if len(args) == 0 {
+		names, err := snapshots()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("no snapshots")
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	file := args[0]
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		dir, err := backupDir()
+		if err != nil {
+			return err
+		}
+		file = path.Join(dir, file)
+	}
+	if err := validateSnapshot(file); err != nil {
+		return err
+	}
+
+	db, err := newDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.close()
+	if err := db.init(); err != nil {
+		return err
+	}
+	safety, err := db.snapshot()
+	if err != nil {
+		return err
+	}
+	if err := db.restore(file); err != nil {
+		return err
+	}
+	fmt.Printf("restored %s, the previous state was saved to %s\n", file, safety)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSnapshotsWithinOneSecond(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	db := newTestDB(t)
+	for i := 0; i < 3; i++ {
+		if _, err := db.snapshot(); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+	}
+	names, err := snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("snapshots = %v, want 3", names)
+	}
+	for i := 1; i < len(names); i++ {
+		a, _ := snapshotTime(names[i-1])
+		b, _ := snapshotTime(names[i])
+		if !a.Before(b) {
+			t.Errorf("snapshots not oldest first: %v", names)
+		}
+	}
+}
+
+func TestSnapshotTime(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 30, 5, 0, time.Local)
+	for name, want := range map[string]time.Time{
+		"data-20240301-123005.db":     want,
+		"data-20240301-123005.250.db": want.Add(250 * time.Millisecond),
+	} {
+		got, ok := snapshotTime(name)
+		if !ok || !got.Equal(want) {
+			t.Errorf("snapshotTime(%q) = %v, %v, want %v", name, got, ok, want)
+		}
+	}
+	if _, ok := snapshotTime("data-latest.db"); ok {
+		t.Error("snapshotTime accepted a name without a time")
+	}
+}
+
+func TestRestoreMakesStaleWritesConflict(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	a, b := openTwice(t)
+	id := mustItem(t, a, mustList(t, a))
+	if _, err := a.updateItemContent(id, "first", 0); err != nil {
+		t.Fatal(err)
+	}
+	file, err := a.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b edits the entry once more and keeps its version while a restores
+	// the snapshot and edits the restored entry.
+	version, err := b.updateItemContent(id, "second", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.restore(file); err != nil {
+		t.Fatal(err)
+	}
+	restored, _, err := a.getItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.content != "first" {
+		t.Fatalf("restored entry = %q, want first", restored.content)
+	}
+	if _, err := a.updateItemContent(id, "after the restore", restored.version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.updateItemContent(id, "stale", version); !errors.Is(err, errConflict) {
+		t.Errorf("write with a version from before the restore: err = %v, want errConflict", err)
+	}
+}
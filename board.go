@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type Layout int
+
+const (
+	listLayout Layout = iota
+	boardLayout
+	splitLayout
+)
+
+var layoutNames = map[string]Layout{
+	"list":  listLayout,
+	"board": boardLayout,
+	"split": splitLayout,
+}
+
+// minColumnWidth is the narrowest a board column gets, if the lists don't
+// fit the board scrolls sideways.
+const minColumnWidth = 20
+
+// boardTop is the screen row of the first entry in the board.
+const boardTop = 4
+
+func (ui *UI) toggleBoard() {
+	if ui.layout == boardLayout {
+		ui.layout = listLayout
+	} else {
+		ui.layout = boardLayout
+	}
+	ui.calculateWindow()
+}
+
+// moveEntryLeft moves the current entry into the column to the left and
+// follows it there.
+func (ui *UI) moveEntryLeft() {
+	ui.moveEntryToColumn(ui.current - 1)
+}
+
+func (ui *UI) moveEntryRight() {
+	ui.moveEntryToColumn(ui.current + 1)
+}
+
+func (ui *UI) moveEntryToColumn(i int) {
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() || i < 0 || i >= len(ui.lists) {
+		return
+	}
+	id := l.currentItem().id
+	targetID := ui.lists[i].ID
+	if err := ui.moveItems([]int{id}, &ui.lists[i], -1); err != nil {
+		ui.fail(err)
+		return
+	}
+	for j := range ui.lists {
+		if ui.lists[j].ID == targetID {
+			ui.current = j
+			ui.lists[j].row = max(indexOf(&ui.lists[j], id), 0)
+		}
+	}
+	ui.calculateWindow()
+}
+
+// boardSpace is the number of entries a column shows.
+func (ui *UI) boardSpace() int {
+	return max(ui.height()-boardTop-bottomOffset-footerHeight, 0)
+}
+
+// boardColumns returns the width of a column and how many fit on the screen.
+func (ui *UI) boardColumns() (int, int) {
+	available := ui.width() - leftOffset
+	width := max(available/max(len(ui.lists), 1), minColumnWidth)
+	return width, max(available/width, 1)
+}
+
+func renderBoard(ui *UI) {
+	if len(ui.lists) == 0 {
+		ui.renderLine("Press "+actionKey(normalMode, "new-list")+" to create a new list", headerHeight-4)
+		return
+	}
+	width, visible := ui.boardColumns()
+	if ui.current < ui.boardLeft {
+		ui.boardLeft = ui.current
+	} else if ui.current >= ui.boardLeft+visible {
+		ui.boardLeft = ui.current - visible + 1
+	}
+	ui.boardLeft = max(min(ui.boardLeft, len(ui.lists)-visible), 0)
+	for i := ui.boardLeft; i < len(ui.lists) && i < ui.boardLeft+visible; i++ {
+		renderColumn(ui, &ui.lists[i], i == ui.current, leftOffset+(i-ui.boardLeft)*width, width-1)
+	}
+}
+
+func renderColumn(ui *UI, l *List, current bool, x, width int) {
+	titleStyle := darkLight
+	if current {
+		titleStyle = lightDark
+	}
+	if current && ui.mode == editListNameMode {
+		ui.editor.render(ui, x, 1, width, darkLight)
+	} else {
+		for col := 0; col < width; col++ {
+			ui.screen.SetContent(x+col, 1, ' ', nil, titleStyle)
+		}
+		drawClipped(ui, x, 1, width, " "+l.name, titleStyle)
+	}
+	var done int
+	for _, item := range l.items {
+		if item.done {
+			done++
+		}
+	}
+	for col := 0; col < width; col++ {
+		ui.screen.SetContent(x+col, 2, ' ', nil, primaryLight)
+	}
+	if len(l.items) != 0 {
+		drawClipped(ui, x, 2, width, fmt.Sprintf(" %d / %d done", done, len(l.items)), primaryLight)
+	}
+
+	// The rows of the column are the shown entries and the "Completed"
+	// heading above the done ones, l.top counts both.
+	space := ui.boardSpace()
+	visible := l.visible()
+	selected := ui.selected(l)
+	completed := l.completedAt()
+	rows := len(visible)
+	if completed >= 0 {
+		rows++
+	}
+	if len(visible) == 0 && len(l.items) != 0 {
+		drawClipped(ui, x, boardTop, width, "All entries are done", darkSecondary)
+	}
+	row := max(l.position(l.row), 0)
+	if completed >= 0 && row >= completed {
+		row++
+	}
+	if row < l.top {
+		l.top = row
+	} else if row >= l.top+space {
+		l.top = row - space + 1
+	}
+	l.top = max(min(l.top, rows-space), 0)
+	for r := l.top; r < rows && r < l.top+space; r++ {
+		y := boardTop + r - l.top
+		p := r
+		if completed >= 0 && r >= completed {
+			if r == completed {
+				drawClipped(ui, x, y, width, l.completedHeading(), darkSecondary)
+				continue
+			}
+			p--
+		}
+		i := visible[p]
+		item := l.items[i]
+		style := darkLight
+		if current && i == l.row && ui.mode != editListNameMode {
+			style = primaryLight
+		} else if selected[i] {
+			style = secondaryDark
+		}
+		marker := "[ ] "
+		if item.done {
+			marker = "[X] "
+		}
+		drawClipped(ui, x, y, width, marker, darkLight)
+		if current && i == l.row && ui.mode == editMode {
+			ui.editor.render(ui, x+4, y, width-4, darkLight)
+			continue
+		}
+		w := drawClipped(ui, x+4, y, width-4, item.content, style)
+		drawClipped(ui, x+4+w, y, width-4-w, item.extras(), darkSecondary)
+	}
+}
+
+// drawClipped draws s from x on, leaving out what does not fit into width
+// cells, and returns the width drawn.
+func drawClipped(ui *UI, x, y, width int, s string, style tcell.Style) int {
+	start := x
+	for _, c := range graphemes(s) {
+		if x+clusterWidth(c) > start+width {
+			break
+		}
+		x += drawCluster(ui, x, y, c, style)
+	}
+	return x - start
+}
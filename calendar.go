@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// cellWidth is the width of a day in the month grid, every week takes two
+// lines, the day and the number of entries due on it.
+const cellWidth = 5
+
+const calendarTop = 3
+
+// calendar keeps the selected day and the selected entry due on it. moving
+// is the id of an entry that is being moved to another day, 0 if none. back
+// is where the lists were when the calendar was opened.
+type calendar struct {
+	day    time.Time
+	row    int
+	moving int
+	back   listCursor
+}
+
+func (ui *UI) openCalendar() {
+	ui.calendar = calendar{day: startOfDay(time.Now()), back: ui.saveCursor()}
+	if l := ui.currentList(); l != nil && len(l.items) != 0 {
+		if t, err := parseDate(l.currentItem().due); err == nil {
+			ui.calendar.day = t
+			for i, e := range ui.entriesDue(t) {
+				if ui.agendaItem(e).id == l.currentItem().id {
+					ui.calendar.row = i
+				}
+			}
+		}
+	}
+	ui.view = calendarMode
+	ui.mode = calendarMode
+	ui.syncCalendar()
+}
+
+// closeCalendar goes back to the list and entry the calendar was opened
+// from.
+func (ui *UI) closeCalendar() {
+	ui.restoreCursor(ui.calendar.back)
+	ui.view = normalMode
+	ui.mode = normalMode
+	ui.calculateWindow()
+}
+
+// entriesDue returns the entries of all lists due on day.
+func (ui *UI) entriesDue(day time.Time) []agendaEntry {
+	due := day.Format(dateFormat)
+	var entries []agendaEntry
+	for i := range ui.lists {
+		if ui.lists[i].smart() {
+			continue
+		}
+		for row, item := range ui.lists[i].items {
+			if item.due == due {
+				entries = append(entries, agendaEntry{list: i, row: row})
+			}
+		}
+	}
+	return entries
+}
+
+// dueCounts returns the number of entries due per day.
+func (ui *UI) dueCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, l := range ui.realLists() {
+		for _, item := range l.items {
+			if item.due != "" {
+				counts[item.due]++
+			}
+		}
+	}
+	return counts
+}
+
+// syncCalendar makes the selected entry the current entry of its list, so
+// editing and commands work on it.
+func (ui *UI) syncCalendar() {
+	entries := ui.entriesDue(ui.calendar.day)
+	ui.calendar.row = max(min(ui.calendar.row, len(entries)-1), 0)
+	if len(entries) == 0 {
+		return
+	}
+	e := entries[ui.calendar.row]
+	ui.current = e.list
+	ui.lists[e.list].row = e.row
+}
+
+func (ui *UI) moveDay(days int) {
+	ui.calendar.day = ui.calendar.day.AddDate(0, 0, days)
+	ui.calendar.row = 0
+	ui.syncCalendar()
+}
+
+func (ui *UI) moveMonth(months int) {
+	d := ui.calendar.day
+	first := time.Date(d.Year(), d.Month()+time.Month(months), 1, 0, 0, 0, 0, d.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	ui.calendar.day = first.AddDate(0, 0, min(d.Day(), last)-1)
+	ui.calendar.row = 0
+	ui.syncCalendar()
+}
+
+func (ui *UI) previousDay() {
+	ui.moveDay(-1)
+}
+
+func (ui *UI) nextDay() {
+	ui.moveDay(1)
+}
+
+func (ui *UI) previousWeek() {
+	ui.moveDay(-7)
+}
+
+func (ui *UI) nextWeek() {
+	ui.moveDay(7)
+}
+
+func (ui *UI) previousMonth() {
+	ui.moveMonth(-1)
+}
+
+func (ui *UI) nextMonth() {
+	ui.moveMonth(1)
+}
+
+func (ui *UI) calendarToday() {
+	ui.calendar.day = startOfDay(time.Now())
+	ui.calendar.row = 0
+	ui.syncCalendar()
+}
+
+func (ui *UI) calendarEntryDown() {
+	ui.calendar.row++
+	ui.syncCalendar()
+}
+
+func (ui *UI) calendarEntryUp() {
+	ui.calendar.row = max(ui.calendar.row-1, 0)
+	ui.syncCalendar()
+}
+
+func (ui *UI) calendarToggle() {
+	if ui.calendar.moving != 0 {
+		ui.dropEntry()
+		return
+	}
+	entries := ui.entriesDue(ui.calendar.day)
+	if len(entries) == 0 {
+		return
+	}
+	item := ui.agendaItem(entries[ui.calendar.row])
+	ids := []int{item.id}
+	err := ui.change("toggle", ids, nil, func(step *undoStep) error {
+		return ui.db.updateItems(ids, "done", !item.done)
+	})
+	if err != nil {
+		ui.fail(err)
+	}
+	ui.syncCalendar()
+}
+
+// calendarMove picks up the selected entry or drops the one picked up on
+// the selected day.
+func (ui *UI) calendarMove() {
+	if ui.calendar.moving != 0 {
+		ui.dropEntry()
+	} else {
+		ui.pickEntry()
+	}
+}
+
+// pickEntry starts moving the selected entry, it gets the day that is
+// selected when it is dropped.
+func (ui *UI) pickEntry() {
+	entries := ui.entriesDue(ui.calendar.day)
+	if len(entries) == 0 {
+		return
+	}
+	ui.calendar.moving = ui.agendaItem(entries[ui.calendar.row]).id
+}
+
+func (ui *UI) dropEntry() {
+	id := ui.calendar.moving
+	ui.calendar.moving = 0
+	if err := ui.setDue([]int{id}, ui.calendar.day.Format(dateFormat)); err != nil {
+		ui.fail(err)
+		return
+	}
+	for i, e := range ui.entriesDue(ui.calendar.day) {
+		if ui.agendaItem(e).id == id {
+			ui.calendar.row = i
+		}
+	}
+	ui.syncCalendar()
+}
+
+// calendarCancel stops moving an entry or else leaves the calendar.
+func (ui *UI) calendarCancel() {
+	if ui.calendar.moving != 0 {
+		ui.calendar.moving = 0
+		return
+	}
+	ui.closeCalendar()
+}
+
+// movingEntry returns the entry being moved.
+func (ui *UI) movingEntry() *Item {
+	for i := range ui.lists {
+		if item := ui.lists[i].itemById(ui.calendar.moving); item != nil {
+			return item
+		}
+	}
+	return nil
+}
+
+func renderCalendar(ui *UI) {
+	drawClipped(ui, leftOffset, 1, ui.width()-2*leftOffset, padChunk("Calendar"), lightDark)
+	day := ui.calendar.day
+	today := startOfDay(time.Now())
+	counts := ui.dueCounts()
+
+	gridWidth := 7 * cellWidth
+	title := day.Format("January 2006")
+	drawText(ui, leftOffset+(gridWidth-textWidth(title))/2, calendarTop, title, darkLight)
+	for i, name := range []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"} {
+		drawText(ui, leftOffset+i*cellWidth+1, calendarTop+1, name, darkSecondary)
+	}
+	first := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	for d := first; d.Month() == day.Month(); d = d.AddDate(0, 0, 1) {
+		week := (weekday(first) + d.Day() - 1) / 7
+		x := leftOffset + weekday(d)*cellWidth
+		y := calendarTop + 2 + 2*week
+		style := darkLight
+		switch {
+		case d.Equal(day):
+			style = primaryLight
+		case d.Equal(today):
+			style = lightDark
+		}
+		drawText(ui, x, y, fmt.Sprintf("%3d ", d.Day()), style)
+		if n := counts[d.Format(dateFormat)]; n != 0 {
+			drawText(ui, x, y+1, fmt.Sprintf("%3s ", strconv.Itoa(n)), darkSecondary)
+		}
+	}
+
+	// The entries of the day go next to the grid or below it on narrow
+	// terminals.
+	x, y := leftOffset+gridWidth+2, calendarTop
+	if ui.width()-x < 30 {
+		x, y = leftOffset, calendarTop+2+2*6+1
+	}
+	width := ui.width() - x - leftOffset
+	bottom := ui.height() - footerHeight - bottomOffset
+	drawClipped(ui, x, y, width, separator(ui, padChunk(day.Format("Monday, 2 January")), ui.width()-width-2), primaryLight)
+	y++
+	entries := ui.entriesDue(day)
+	if len(entries) == 0 {
+		drawClipped(ui, x, y, width, "Nothing is due", darkSecondary)
+	}
+	for i, e := range entries {
+		if y+i >= bottom {
+			break
+		}
+		item := ui.agendaItem(e)
+		style := darkLight
+		if i == ui.calendar.row && ui.calendar.moving == 0 && ui.mode != editMode {
+			style = primaryLight
+		}
+		marker := "[ ] "
+		if item.done {
+			marker = "[X] "
+		}
+		name := ui.lists[e.list].name
+		nameWidth := min(textWidth(name), width/3)
+		drawClipped(ui, x+width-nameWidth, y+i, nameWidth, name, darkSecondary)
+		drawClipped(ui, x, y+i, 4, marker, darkLight)
+		available := width - nameWidth - 5
+		if i == ui.calendar.row && ui.mode == editMode {
+			ui.editor.render(ui, x+4, y+i, available, darkLight)
+			continue
+		}
+		drawClipped(ui, x+4, y+i, available, item.content, style)
+	}
+}
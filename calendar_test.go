@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloseCalendarGoesBack(t *testing.T) {
+	ui := newTestUI(t)
+	first, second := mustList(t, ui.db), mustList(t, ui.db)
+	current := mustItem(t, ui.db, first)
+	done := mustItem(t, ui.db, second)
+	open := mustItem(t, ui.db, second)
+	if err := ui.db.updateItems([]int{done}, "due", time.Now().Format(dateFormat)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ui.db.updateItems([]int{done}, "done", true); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	ui.lists[1].doneView = doneHidden
+	ui.lists[1].row = indexOf(&ui.lists[1], open)
+
+	// The calendar selects the done entry due today, which the second
+	// list hides.
+	ui.openCalendar()
+	if ui.current != 1 || ui.currentList().currentItem().id != done {
+		t.Fatalf("calendar starts on list %d, row %d, want the due entry", ui.current, ui.currentList().row)
+	}
+	ui.closeCalendar()
+	if ui.current != 0 || ui.currentList().currentItem().id != current {
+		t.Errorf("after closing the calendar: list %d, want the list it was opened on", ui.current)
+	}
+	if l := ui.lists[1]; l.currentItem().id != open {
+		t.Errorf("second list stays on row %d, want the open entry", l.row)
+	}
+}
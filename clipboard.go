@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// register holds the entries yanked last. The same text goes to the system
+// clipboard, pasting it back uses the register to keep done, tags and
+// priority.
+type register struct {
+	items []Item
+	text  string
+}
+
+var errEmptyClipboard = errors.New("nothing to paste")
+
+func (ui *UI) yank() {
+	if l := ui.currentList(); l != nil && l.hasCurrent() {
+		ui.yankItems([]Item{*l.currentItem()})
+	}
+}
+
+func (ui *UI) visualYank() {
+	l := ui.currentList()
+	var items []Item
+	for i, ok := range ui.selected(l) {
+		if ok {
+			items = append(items, l.items[i])
+		}
+	}
+	ui.leaveVisual()
+	ui.yankItems(items)
+}
+
+func (ui *UI) yankItems(items []Item) {
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, item.content)
+	}
+	ui.register = register{items: items, text: strings.Join(lines, "\n")}
+	if config.systemClipboard {
+		if err := ui.writeClipboard(ui.register.text); err != nil {
+			ui.fail(err)
+			return
+		}
+	}
+	ui.notify(fmt.Sprintf("Yanked %s", entries(len(items))))
+}
+
+func (ui *UI) pasteBelow() {
+	ui.paste(1)
+}
+
+func (ui *UI) pasteAbove() {
+	ui.paste(0)
+}
+
+// paste adds the clipboard below or above the current entry, one entry per
+// line.
+func (ui *UI) paste(offset int) {
+	l := ui.currentList()
+	if l == nil {
+		return
+	}
+	items := ui.register.items
+	if config.systemClipboard {
+		if text, ok := readClipboard(); ok && strings.TrimSpace(text) != strings.TrimSpace(ui.register.text) {
+			items = parseEntries(text)
+		}
+	}
+	if len(items) == 0 {
+		ui.fail(errEmptyClipboard)
+		return
+	}
+	pos := 0
+	if len(l.items) != 0 {
+		pos = l.row + offset
+	}
+	if err := ui.insertItems(l, items, pos); err != nil {
+		ui.fail(err)
+	}
+}
+
+// insertItems adds the entries to l at pos as one undoable step and moves
+// the cursor to the first of them.
+func (ui *UI) insertItems(l *List, items []Item, pos int) error {
+	if l.smart() {
+		return errSmartList
+	}
+	listID := l.ID
+	var ids []int
+	text := fmt.Sprintf("paste %s", entries(len(items)))
+	err := ui.change(text, nil, []*List{l}, func(step *undoStep) error {
+		var err error
+		ids, err = ui.db.insertItems(listID, items)
+		step.created = ids
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	ui.placeItems(listID, ids, pos)
+	if l := ui.listByID(listID); l != nil {
+		l.row = max(indexOf(l, ids[0]), 0)
+		ui.calculateWindow()
+	}
+	return nil
+}
+
+// parseEntries turns text into one entry per non-empty line. Markdown task
+// lines as written by :export keep their done state.
+func parseEntries(text string) []Item {
+	var items []Item
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		var item Item
+		switch {
+		case strings.HasPrefix(line, "- [ ] "):
+			line = line[6:]
+		case strings.HasPrefix(line, "- [x] "), strings.HasPrefix(line, "- [X] "):
+			line = line[6:]
+			item.done = true
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			line = line[2:]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			item.content = line
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// writeClipboard sets the system clipboard with an OSC 52 escape sequence
+// written to the terminal of the screen, which also works over SSH, and with
+// wl-copy, xclip or pbcopy if one of them is installed, for terminals that
+// ignore OSC 52.
+func (ui *UI) writeClipboard(text string) error {
+	osc52 := false
+	if ui.screen != nil {
+		if tty, ok := ui.screen.Tty(); ok {
+			_, err := fmt.Fprintf(tty, "\x1b]52;c;%s\x07", base64.StdEncoding.EncodeToString([]byte(text)))
+			if err != nil {
+				return err
+			}
+			osc52 = true
+		}
+	}
+	name, args, ok := clipboardCommand(copyCommands)
+	if !ok {
+		if !osc52 {
+			return errors.New("there is no clipboard to copy to")
+		}
+		return nil
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// readClipboard reads the system clipboard. Terminals rarely allow reading
+// it with OSC 52, so this needs one of the clipboard commands.
+func readClipboard() (string, bool) {
+	name, args, ok := clipboardCommand(pasteCommands)
+	if !ok {
+		return "", false
+	}
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+var copyCommands = [][]string{
+	{"WAYLAND_DISPLAY", "wl-copy"},
+	{"DISPLAY", "xclip", "-selection", "clipboard"},
+	{"", "pbcopy"},
+}
+
+var pasteCommands = [][]string{
+	{"WAYLAND_DISPLAY", "wl-paste", "--no-newline"},
+	{"DISPLAY", "xclip", "-selection", "clipboard", "-o"},
+	{"", "pbpaste"},
+}
+
+// clipboardCommand returns the first of the commands that is installed and
+// whose display variable is set.
+func clipboardCommand(commands [][]string) (string, []string, bool) {
+	for _, c := range commands {
+		if c[0] != "" && os.Getenv(c[0]) == "" {
+			continue
+		}
+		if _, err := exec.LookPath(c[1]); err == nil {
+			return c[1], c[2:], true
+		}
+	}
+	return "", nil, false
+}
@@ -1,6 +1,10 @@
 package main
 
-import "github.com/gdamore/tcell"
+import (
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
 
 var dark tcell.Color = tcell.NewRGBColor(26, 27, 44)
 var light tcell.Color = tcell.NewRGBColor(192, 202, 245)
@@ -8,23 +12,135 @@ var primary tcell.Color = tcell.NewRGBColor(40, 52, 74)
 var secondary tcell.Color = tcell.NewRGBColor(115, 218, 202)
 var tertiary tcell.Color = tcell.NewRGBColor(247, 118, 142)
 
+// Theme sets the five colors everything is drawn with. dark is the
+// background and light the text, the styles pair them up with the accents
+// and reverse them for highlights.
+type Theme struct {
+	name      string
+	dark      tcell.Color
+	light     tcell.Color
+	primary   tcell.Color
+	secondary tcell.Color
+	tertiary  tcell.Color
+	// truecolor themes are replaced by the terminal theme on terminals
+	// with less than 256 colors
+	truecolor bool
+}
+
+var themes = []Theme{
+	{"dark", dark, light, primary, secondary, tertiary, true},
+	{
+		"light",
+		tcell.NewRGBColor(245, 245, 240),
+		tcell.NewRGBColor(26, 27, 44),
+		tcell.NewRGBColor(200, 211, 245),
+		tcell.NewRGBColor(42, 157, 143),
+		tcell.NewRGBColor(209, 73, 91),
+		true,
+	},
+	{
+		"high-contrast",
+		tcell.NewRGBColor(0, 0, 0),
+		tcell.NewRGBColor(255, 255, 255),
+		tcell.NewRGBColor(0, 0, 170),
+		tcell.NewRGBColor(0, 125, 0),
+		tcell.NewRGBColor(190, 0, 0),
+		true,
+	},
+	{"terminal", tcell.ColorDefault, tcell.ColorDefault, tcell.ColorBlue, tcell.ColorTeal, tcell.ColorRed, false},
+}
+
+// monochrome is used if NO_COLOR is set, highlights are only reversed.
+var monochrome = Theme{"monochrome", tcell.ColorDefault, tcell.ColorDefault, tcell.ColorDefault, tcell.ColorDefault, tcell.ColorDefault, false}
+
+var currentTheme = themes[0]
+
+// colorNames are the colors that can be set in the [colors] section of the
+// config file.
+var colorNames = map[string]*tcell.Color{
+	"dark":      &dark,
+	"light":     &light,
+	"primary":   &primary,
+	"secondary": &secondary,
+	"tertiary":  &tertiary,
+}
+
 // bgcolorFgcolor
-var darkLight = createStyle(dark, light)
-var lightDark = darkLight.Reverse(true)
-
-var darkPrimary = createStyle(dark, primary)
-var darkSecondary = createStyle(dark, secondary)
-var darkTertiary = createStyle(dark, tertiary)
-var primaryDark = darkPrimary.Reverse(true)
-var secondaryDark = darkSecondary.Reverse(true)
-var tertiaryDark = darkTertiary.Reverse(true)
-
-var lightPrimary = createStyle(light, primary)
-var lightSecondary = createStyle(light, secondary)
-var lightTertiary = createStyle(light, tertiary)
-var primaryLight = lightPrimary.Reverse(true)
-var secondaryLight = lightSecondary.Reverse(true)
-var tertiaryLight = lightTertiary.Reverse(true)
+var darkLight tcell.Style
+var lightDark tcell.Style
+
+var darkPrimary tcell.Style
+var darkSecondary tcell.Style
+var darkTertiary tcell.Style
+var primaryDark tcell.Style
+var secondaryDark tcell.Style
+var tertiaryDark tcell.Style
+
+var lightPrimary tcell.Style
+var lightSecondary tcell.Style
+var lightTertiary tcell.Style
+var primaryLight tcell.Style
+var secondaryLight tcell.Style
+var tertiaryLight tcell.Style
+
+func init() {
+	deriveStyles()
+}
+
+func themeByName(name string) (Theme, bool) {
+	for _, t := range themes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return Theme{}, false
+}
+
+// applyTheme switches to the theme, keeping the colors set in the config
+// file. colors is the number of colors the terminal supports.
+func applyTheme(t Theme, colors int) {
+	if os.Getenv("NO_COLOR") != "" {
+		t = monochrome
+	} else if t.truecolor && colors > 0 && colors < 256 {
+		t, _ = themeByName("terminal")
+	}
+	currentTheme = t
+	dark, light, primary, secondary, tertiary = t.dark, t.light, t.primary, t.secondary, t.tertiary
+	if t != monochrome {
+		for name, color := range config.colors {
+			*colorNames[name] = color
+		}
+	}
+	deriveStyles()
+}
+
+func nextTheme() Theme {
+	for i, t := range themes {
+		if t.name == currentTheme.name {
+			return themes[(i+1)%len(themes)]
+		}
+	}
+	return themes[0]
+}
+
+func deriveStyles() {
+	darkLight = createStyle(dark, light)
+	lightDark = darkLight.Reverse(true)
+
+	darkPrimary = createStyle(dark, primary)
+	darkSecondary = createStyle(dark, secondary)
+	darkTertiary = createStyle(dark, tertiary)
+	primaryDark = darkPrimary.Reverse(true)
+	secondaryDark = darkSecondary.Reverse(true)
+	tertiaryDark = darkTertiary.Reverse(true)
+
+	lightPrimary = createStyle(light, primary)
+	lightSecondary = createStyle(light, secondary)
+	lightTertiary = createStyle(light, tertiary)
+	primaryLight = lightPrimary.Reverse(true)
+	secondaryLight = lightSecondary.Reverse(true)
+	tertiaryLight = lightTertiary.Reverse(true)
+}
 
 func createStyle(bg, fg tcell.Color) tcell.Style {
 	return tcell.StyleDefault.Background(bg).Foreground(fg)
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Command struct {
+	name  string
+	usage string
+	text  string
+	// listArg marks commands whose argument completes to list names
+	listArg bool
+	run     func(ui *UI, arg string) error
+}
+
+type commandLine struct {
+	input       string
+	history     []string
+	historyPos  int
+	completions []string
+	completion  int
+}
+
+var commands = []Command{
+	{"rename", "rename <name>", "rename the current list", false, (*UI).cmdRename},
+	{"sort", "sort [" + sortUsage + "]", "sort the current list, by name unless a key is given", false, (*UI).cmdSort},
+	{"keep-sorted", "keep-sorted [" + sortUsage + "]|off", "sort the current list after every change", false, (*UI).cmdKeepSorted},
+	{"move", "move <list> [top|bottom|<n>]", "move the current or selected entries to another list", true, (*UI).cmdMove},
+	{"copy", "copy <list> [top|bottom|<n>]", "copy the current or selected entries to a list", true, (*UI).cmdCopy},
+	{"tag", "tag <tag>...", "add tags to the current or selected entries, -<tag> removes one", false, (*UI).cmdTag},
+	{"priority", "priority <0-3>", "set the priority of the current or selected entries", false, (*UI).cmdPriority},
+	{"note", "note [text]", "set the notes of the current or selected entries", false, (*UI).cmdNote},
+	{"due", "due <date|today|tomorrow|+<n>d|none>", "set the due date of the current or selected entries", false, (*UI).cmdDue},
+	{"export", "export [file]", "write the current list to a markdown file", false, (*UI).cmdExport},
+	{"done", "done show|hide|bottom|collapse", "show, hide or gather the done entries of the current list", false, (*UI).cmdDone},
+	{"smart", "smart " + queryUsage, "add a smart list showing the entries of all lists that match the query", false, (*UI).cmdSmart},
+	{"query", "query [" + queryUsage + "]", "change the query of the current smart list", false, (*UI).cmdQuery},
+	{"archive", "archive", "move the done entries into the Archive list", false, (*UI).cmdArchive},
+	{"w", "w", "save the order of all lists and entries", false, (*UI).cmdWrite},
+	{"q", "q", "exit", false, (*UI).cmdQuit},
+	{"wq", "wq", "save and exit", false, (*UI).cmdWriteQuit},
+}
+
+var errNoList = errors.New("there is no list")
+var errNoEntry = errors.New("the list has no entries")
+
+func (ui *UI) enterCommand() {
+	ui.promptCommand("")
+}
+
+// promptCommand opens the command line with input already typed in.
+func (ui *UI) promptCommand(input string) {
+	ui.command.input = input
+	ui.command.historyPos = len(ui.command.history)
+	ui.command.completions = nil
+	ui.mode = commandMode
+}
+
+// cancelCommand returns to the mode the command line was opened from.
+func (ui *UI) cancelCommand() {
+	if ui.selection.active {
+		ui.mode = visualMode
+	} else {
+		ui.mode = ui.view
+	}
+}
+
+func handleCommandModeEv(ui *UI, r rune, isRune bool) {
+	if isRune {
+		ui.command.input += string(r)
+		ui.command.completions = nil
+	}
+}
+
+func (ui *UI) commandDeleteRune() {
+	input := []rune(ui.command.input)
+	if len(input) == 0 {
+		ui.cancelCommand()
+		return
+	}
+	ui.command.input = string(input[:len(input)-1])
+	ui.command.completions = nil
+}
+
+func (ui *UI) commandHistoryPrev() {
+	if ui.command.historyPos > 0 {
+		ui.command.historyPos--
+		ui.command.input = ui.command.history[ui.command.historyPos]
+	}
+}
+
+func (ui *UI) commandHistoryNext() {
+	if ui.command.historyPos < len(ui.command.history)-1 {
+		ui.command.historyPos++
+		ui.command.input = ui.command.history[ui.command.historyPos]
+	} else {
+		ui.command.historyPos = len(ui.command.history)
+		ui.command.input = ""
+	}
+}
+
+// commandComplete completes the command name or, for commands taking a list,
+// the list name. Pressing it again cycles through the candidates.
+func (ui *UI) commandComplete() {
+	c := &ui.command
+	if len(c.completions) != 0 {
+		c.completion = (c.completion + 1) % len(c.completions)
+		c.input = c.completions[c.completion]
+		return
+	}
+	name, arg, hasArg := strings.Cut(c.input, " ")
+	var candidates []string
+	if !hasArg {
+		for _, cmd := range commands {
+			if strings.HasPrefix(cmd.name, name) {
+				candidates = append(candidates, cmd.name)
+			}
+		}
+	} else if cmd := commandByName(name); cmd != nil && cmd.listArg {
+		for _, l := range ui.lists {
+			if strings.HasPrefix(strings.ToLower(l.name), strings.ToLower(strings.TrimSpace(arg))) {
+				candidates = append(candidates, name+" "+l.name)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	c.completions = candidates
+	c.completion = 0
+	c.input = candidates[0]
+}
+
+func (ui *UI) runCommand() {
+	input := strings.TrimSpace(ui.command.input)
+	ui.mode = ui.view
+	// The selection of the visual mode only lasts for one command.
+	defer func() { ui.selection = selection{} }()
+	if input == "" {
+		return
+	}
+	if h := ui.command.history; len(h) == 0 || h[len(h)-1] != input {
+		ui.command.history = append(ui.command.history, input)
+	}
+	name, arg, _ := strings.Cut(input, " ")
+	cmd := commandByName(name)
+	if cmd == nil {
+		ui.fail(fmt.Errorf("unknown command: %s", name))
+		return
+	}
+	if err := cmd.run(ui, strings.TrimSpace(arg)); err != nil {
+		ui.fail(err)
+	}
+}
+
+func commandByName(name string) *Command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func (ui *UI) cmdRename(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if arg == "" {
+		return errors.New("usage: rename <name>")
+	}
+	l.name = arg
+	if conflict, err := l.updateName(ui.db); err != nil {
+		return err
+	} else if conflict {
+		ui.notify("List name was changed in another instance, kept your version")
+	}
+	ui.changed()
+	return nil
+}
+
+func (ui *UI) cmdSort(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	o, err := parseSortOrder(arg)
+	if err != nil {
+		return err
+	}
+	if !ui.checkUnsorted() {
+		return nil
+	}
+	if l.sortItems(o) {
+		ui.calculateWindow()
+		ui.saveItemOrder()
+	}
+	return nil
+}
+
+func (ui *UI) cmdKeepSorted(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if l.smart() {
+		return errSmartList
+	}
+	var o sortOrder
+	if arg != "off" {
+		var err error
+		if o, err = parseSortOrder(arg); err != nil {
+			return errors.New("usage: keep-sorted [" + sortUsage + "]|off")
+		}
+	}
+	if err := ui.db.updateListSortOrder(l.ID, o); err != nil {
+		return err
+	}
+	l.sortOrder = o
+	if o.key == "" {
+		ui.notify("The list is no longer kept sorted")
+		return nil
+	}
+	ui.keepSorted()
+	ui.notify("The list is kept sorted by " + o.String())
+	return nil
+}
+
+func (ui *UI) cmdMove(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if !l.hasCurrent() {
+		return errNoEntry
+	}
+	target, pos, err := ui.findTarget(arg)
+	if err != nil {
+		return err
+	}
+	if target.ID == l.ID {
+		ui.placeItems(l.ID, ui.targetIDs(), pos)
+		return nil
+	}
+	return ui.moveItems(ui.targetIDs(), target, pos)
+}
+
+func (ui *UI) cmdCopy(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if !l.hasCurrent() {
+		return errNoEntry
+	}
+	target, pos, err := ui.findTarget(arg)
+	if err != nil {
+		return err
+	}
+	return ui.copyItems(ui.targetIDs(), target, pos)
+}
+
+// findTarget parses the "<list> [top|bottom|<n>]" argument of :move and
+// :copy. The position is an index into the target list, -1 for the end.
+func (ui *UI) findTarget(arg string) (*List, int, error) {
+	l, pos, err := ui.findTargetList(arg)
+	if err == nil && l.smart() {
+		return nil, -1, errSmartList
+	}
+	return l, pos, err
+}
+
+func (ui *UI) findTargetList(arg string) (*List, int, error) {
+	if l, err := ui.findList(arg); err == nil {
+		return l, -1, nil
+	}
+	fields := strings.Fields(arg)
+	if len(fields) < 2 {
+		l, err := ui.findList(arg)
+		return l, -1, err
+	}
+	last := fields[len(fields)-1]
+	pos := -1
+	switch last {
+	case "top":
+		pos = 0
+	case "bottom":
+	default:
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 1 {
+			l, err := ui.findList(arg)
+			return l, -1, err
+		}
+		pos = n - 1
+	}
+	l, err := ui.findList(strings.Join(fields[:len(fields)-1], " "))
+	return l, pos, err
+}
+
+// findList finds a list by its number in the navigation, by the start of its
+// name or, failing that, by a name containing the letters of arg in order.
+func (ui *UI) findList(arg string) (*List, error) {
+	if arg == "" {
+		return nil, errors.New("no list given")
+	}
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(ui.lists) {
+			return nil, fmt.Errorf("there is no list %d", n)
+		}
+		return &ui.lists[n-1], nil
+	}
+	for i := range ui.lists {
+		if strings.EqualFold(ui.lists[i].name, arg) {
+			return &ui.lists[i], nil
+		}
+	}
+	for _, match := range []func(name, arg string) bool{strings.HasPrefix, fuzzyMatch} {
+		var found *List
+		for i := range ui.lists {
+			if match(strings.ToLower(ui.lists[i].name), strings.ToLower(arg)) {
+				if found != nil {
+					return nil, fmt.Errorf("%q matches more than one list", arg)
+				}
+				found = &ui.lists[i]
+			}
+		}
+		if found != nil {
+			return found, nil
+		}
+	}
+	return nil, fmt.Errorf("no list matches %q", arg)
+}
+
+// fuzzyMatch reports whether the runes of pattern appear in s in order.
+func fuzzyMatch(s, pattern string) bool {
+	rest := []rune(pattern)
+	for _, r := range s {
+		if len(rest) == 0 {
+			break
+		}
+		if r == rest[0] {
+			rest = rest[1:]
+		}
+	}
+	return len(rest) == 0
+}
+
+func (ui *UI) cmdExport(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	file := arg
+	if file == "" {
+		file = fileName(l.name) + ".md"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", l.name)
+	for _, item := range l.items {
+		marker := " "
+		if item.done {
+			marker = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] %s\n", marker, item.content)
+	}
+	if err := os.WriteFile(file, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	ui.notify("Exported to " + file)
+	return nil
+}
+
+func fileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "list"
+	}
+	return name
+}
+
+func (ui *UI) cmdArchive(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	current := l.ID
+	archive := ui.listByName("Archive")
+	if archive == nil {
+		if len(ui.realLists()) >= maxLists {
+			return errors.New("there is no room for an Archive list")
+		}
+		id, err := ui.db.createList()
+		if err != nil {
+			return err
+		}
+		archive = &ui.lists[ui.appendList(List{ID: id, name: "Archive"})]
+		archive.updateName(ui.db)
+		ui.saveListOrder()
+		l = ui.listByID(current)
+	}
+	if archive.ID == l.ID {
+		return errors.New("the Archive list cannot be archived")
+	}
+	var ids []int
+	for _, item := range l.items {
+		if item.done {
+			ids = append(ids, item.id)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New("there are no done entries")
+	}
+	if err := ui.moveItems(ids, archive, -1); err != nil {
+		return err
+	}
+	ui.notify(fmt.Sprintf("Archived %s", entries(len(ids))))
+	return nil
+}
+
+// listByName returns the list named name, leaving out the smart lists since
+// they can't take entries.
+func (ui *UI) listByName(name string) *List {
+	for i := range ui.lists {
+		if ui.lists[i].name == name && !ui.lists[i].smart() {
+			return &ui.lists[i]
+		}
+	}
+	return nil
+}
+
+func (ui *UI) cmdWrite(arg string) error {
+	for i := range ui.lists {
+		if ui.lists[i].smart() {
+			continue
+		}
+		if _, err := ui.db.saveItemOrder(&ui.lists[i]); err != nil {
+			return err
+		}
+	}
+	if _, err := ui.db.saveListOrder(ui.realLists()); err != nil {
+		return err
+	}
+	ui.notify("Saved")
+	return nil
+}
+
+func (ui *UI) cmdQuit(arg string) error {
+	ui.exit()
+	return nil
+}
+
+func (ui *UI) cmdWriteQuit(arg string) error {
+	if err := ui.cmdWrite(arg); err != nil {
+		return err
+	}
+	return ui.cmdQuit(arg)
+}
+
+func renderCommandLine(ui *UI, col, row int) {
+	line := ":" + ui.command.input
+	renderChunk(ui, line, primaryLight, col, row)
+	renderChunk(ui, " ", secondaryLight, col+textWidth(line), row)
+}
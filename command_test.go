@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestArchiveSkipsSmartLists(t *testing.T) {
+	ui := newTestUI(t)
+	listID := mustList(t, ui.db)
+	id := mustItem(t, ui.db, listID)
+	if _, err := ui.db.updateItemDone(id, true, -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ui.db.createSmartList("Archive", "done"); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	if err := ui.cmdArchive(""); err != nil {
+		t.Fatal(err)
+	}
+	archive := ui.listByName("Archive")
+	if archive == nil || archive.smart() || archive.itemById(id) == nil {
+		t.Errorf("the entry did not go to a new Archive list, lists = %+v", ui.lists)
+	}
+}
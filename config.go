@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+type Config struct {
+	keys              map[Mode]map[string][]string
+	theme             Theme
+	colors            map[string]tcell.Color
+	liveRefresh       bool
+	refreshInterval   time.Duration
+	backupRetention   int
+	confirmDeleteList bool
+	systemClipboard   bool
+	splitPaste        bool
+	mouse             bool
+	layout            Layout
+	splitRatio        int
+}
+
+var config = defaultConfig()
+
+// keySections maps the [keys.*] sections of the config file to modes.
+var keySections = map[string]Mode{
+	"keys.normal":   normalMode,
+	"keys.edit":     editMode,
+	"keys.name":     editListNameMode,
+	"keys.delete":   deleteListMode,
+	"keys.help":     helpMode,
+	"keys.command":  commandMode,
+	"keys.visual":   visualMode,
+	"keys.agenda":   agendaMode,
+	"keys.calendar": calendarMode,
+	"keys.stats":    statsMode,
+}
+
+func defaultConfig() Config {
+	return Config{
+		keys:              make(map[Mode]map[string][]string),
+		theme:             themes[0],
+		colors:            make(map[string]tcell.Color),
+		liveRefresh:       true,
+		refreshInterval:   500 * time.Millisecond,
+		backupRetention:   7,
+		confirmDeleteList: true,
+		systemClipboard:   true,
+		mouse:             true,
+		splitRatio:        50,
+	}
+}
+
+func configPath() (string, error) {
+	if p := os.Getenv("TODO_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, "todo", "config.toml"), nil
+}
+
+// loadConfig reads the config file if there is one and applies its keys and
+// colors.
+func loadConfig() error {
+	file, err := configPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c, err := parseConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if err := bindKeys(c.keys); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	config = c
+	return nil
+}
+
+func parseConfig(r io.Reader) (Config, error) {
+	c := defaultConfig()
+	sections, err := parseTOML(r)
+	if err != nil {
+		return c, err
+	}
+	for section, values := range sections {
+		if mode, ok := keySections[section]; ok {
+			c.keys[mode] = make(map[string][]string)
+			for name, v := range values {
+				keys, err := stringList(v)
+				if err != nil {
+					return c, fmt.Errorf("[%s] %s: %w", section, name, err)
+				}
+				c.keys[mode][name] = keys
+			}
+			continue
+		}
+		switch section {
+		case "colors":
+			for name, v := range values {
+				if name == "theme" {
+					s, _ := v.(string)
+					t, ok := themeByName(s)
+					if !ok {
+						return c, fmt.Errorf("[colors] unknown theme %v", v)
+					}
+					c.theme = t
+					continue
+				}
+				if _, ok := colorNames[name]; !ok {
+					return c, fmt.Errorf("[colors] unknown color %q", name)
+				}
+				s, ok := v.(string)
+				if !ok {
+					return c, fmt.Errorf("[colors] %s: expected a string", name)
+				}
+				color := tcell.GetColor(s)
+				if color == tcell.ColorDefault && s != "default" {
+					return c, fmt.Errorf("[colors] %s: invalid color %q", name, s)
+				}
+				c.colors[name] = color
+			}
+		case "behavior":
+			for name, v := range values {
+				if err := c.setBehavior(name, v); err != nil {
+					return c, fmt.Errorf("[behavior] %s: %w", name, err)
+				}
+			}
+		default:
+			return c, fmt.Errorf("unknown section [%s]", section)
+		}
+	}
+	return c, nil
+}
+
+func (c *Config) setBehavior(name string, v any) error {
+	switch name {
+	case "live_refresh":
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		c.liveRefresh = b
+	case "confirm_delete_list":
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		c.confirmDeleteList = b
+	case "system_clipboard":
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		c.systemClipboard = b
+	case "split_paste":
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		c.splitPaste = b
+	case "mouse":
+		b, ok := v.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		c.mouse = b
+	case "layout":
+		name, _ := v.(string)
+		layout, ok := layoutNames[name]
+		if !ok {
+			return errors.New("expected \"list\", \"board\" or \"split\"")
+		}
+		c.layout = layout
+	case "split_ratio":
+		n, ok := v.(int)
+		if !ok || n < minSplitRatio || n > maxSplitRatio {
+			return fmt.Errorf("expected a number from %d to %d", minSplitRatio, maxSplitRatio)
+		}
+		c.splitRatio = n
+	case "refresh_interval_ms":
+		n, ok := v.(int)
+		if !ok || n <= 0 {
+			return errors.New("expected a positive number")
+		}
+		c.refreshInterval = time.Duration(n) * time.Millisecond
+	case "backup_retention":
+		n, ok := v.(int)
+		if !ok || n < 0 {
+			return errors.New("expected a number")
+		}
+		c.backupRetention = n
+	default:
+		return errors.New("unknown setting")
+	}
+	return nil
+}
+
+func stringList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	}
+	return nil, errors.New("expected a key or a list of keys")
+}
+
+// parseTOML parses the subset of TOML the config file needs: [sections] and
+// key = value pairs with strings, integers, booleans and arrays of strings.
+func parseTOML(r io.Reader) (map[string]map[string]any, error) {
+	sections := make(map[string]map[string]any)
+	current := ""
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header", n)
+			}
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := sections[current]; !ok {
+				sections[current] = make(map[string]any)
+			}
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		value, err := parseValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if current == "" {
+			return nil, fmt.Errorf("line %d: %s is outside of a section", n, key)
+		}
+		sections[current][key] = value
+	}
+	return sections, scanner.Err()
+}
+
+func parseValue(raw string) (any, error) {
+	switch {
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'") && strings.HasSuffix(raw, "'") && len(raw) >= 2:
+		return raw[1 : len(raw)-1], nil
+	case strings.HasPrefix(raw, "[") && strings.HasSuffix(raw, "]"):
+		var list []string
+		for _, part := range splitArray(raw[1 : len(raw)-1]) {
+			v, err := parseValue(part)
+			if err != nil {
+				return nil, err
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("arrays may only contain strings")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s", raw)
+	}
+	return n, nil
+}
+
+// splitArray splits the inside of an array at the commas outside of quotes.
+func splitArray(s string) []string {
+	var parts []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -1,47 +1,416 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
 
-type Keymap struct {
-	key  string
+	"github.com/gdamore/tcell/v2"
+)
+
+// Action is something a key can be bound to. run receives the index of the
+// key that triggered it within keys, which lets one action cover a range of
+// keys like the list numbers.
+type Action struct {
+	name string
 	text string
+	keys []string
+	run  func(ui *UI, i int)
 }
 
-func newKeyMap(key string, text string) Keymap {
-	return Keymap{key: key, text: text}
-}
-
-var keymaps = []Keymap{
-	newKeyMap("n", "create new entry"),
-	newKeyMap("N", "create new list"),
-	newKeyMap("", ""),
-	newKeyMap("d", "delete entry"),
-	newKeyMap("D", "delete list"),
-	newKeyMap("", ""),
-	newKeyMap("i", "edit entry"),
-	newKeyMap("I", "edit list name"),
-	newKeyMap("", ""),
-	newKeyMap("j", "go one entry down"),
-	newKeyMap("k", "go one entry up"),
-	newKeyMap("J", "switch entry with the one belowe"),
-	newKeyMap("K", "switch entry with the on above"),
-	newKeyMap("", ""),
-	newKeyMap("h", "go one list to the left"),
-	newKeyMap("l", "go one list to the right"),
-	newKeyMap("H", "switch list with the one to the left"),
-	newKeyMap("L", "switch list with the one to the right"),
-	newKeyMap("", ""),
-	newKeyMap("0-9", "switch to list (0-9)"),
-	newKeyMap("enter", "toggle entry"),
-	newKeyMap("x", "exit"),
+type KeymapSection struct {
+	mode   Mode
+	title  string
+	groups [][]*Action
 }
 
-func printKeymaps() {
-	for _, k := range keymaps {
-		if len(k.key) != 0 {
-			fmt.Printf("%-6s %s\n", k.key, k.text)
+// keymaps is the single registry of actions, the key handlers and the
+// controls overview are both generated from it. It is filled in init since
+// some actions look up their own keys.
+var keymaps []KeymapSection
+
+func defaultKeymaps() []KeymapSection {
+	return withHelp([]KeymapSection{
+		{normalMode, "Normal", [][]*Action{
+			{
+				newAction("new-entry", "create new entry", do((*UI).listAddEntry), "n"),
+				newAction("new-list", "create new list", do((*UI).addList), "N"),
+			},
+			{
+				newAction("delete-entry", "delete entry", do((*UI).listDeleteEntry), "d"),
+				newAction("delete-list", "delete list", do((*UI).enterDeleteListMode), "D"),
+			},
+			{
+				newAction("edit-entry", "edit entry", do((*UI).enterEdit), "i"),
+				newAction("edit-list-name", "edit list name", do((*UI).enterNameEdit), "I"),
+			},
+			{
+				newAction("move-entry", "move entry to another list", do((*UI).promptMove), "m"),
+				newAction("copy-entry", "copy entry to a list", do((*UI).promptCopy), "c"),
+			},
+			{
+				newAction("yank", "yank entry to the clipboard", do((*UI).yank), "y"),
+				newAction("paste-below", "paste the clipboard below the entry", do((*UI).pasteBelow), "p"),
+				newAction("paste-above", "paste the clipboard above the entry", do((*UI).pasteAbove), "P"),
+			},
+			{
+				newAction("down", "go one entry down", do((*UI).listDown), "j"),
+				newAction("up", "go one entry up", do((*UI).listUp), "k"),
+				newAction("move-entry-down", "switch entry with the one below", do((*UI).listSwitchDown), "J"),
+				newAction("move-entry-up", "switch entry with the one above", do((*UI).listSwitchUp), "K"),
+			},
+			{
+				newAction("list-left", "go one list to the left", do((*UI).left), "h"),
+				newAction("list-right", "go one list to the right", do((*UI).right), "l"),
+				newAction("move-list-left", "switch list with the one to the left, on the board move the entry", do((*UI).switchListLeft), "H"),
+				newAction("move-list-right", "switch list with the one to the right, on the board move the entry", do((*UI).switchListRight), "L"),
+				newAction("board", "switch between the list and the board", do((*UI).toggleBoard), "B"),
+			},
+			{
+				newAction("hide-done", "hide or show the done entries", do((*UI).toggleHideDone), "z"),
+				newAction("collapse-done", "gather the done entries at the bottom, collapse or expand them", do((*UI).toggleCollapseDone), "Z"),
+			},
+			{
+				newAction("split", "show or hide the details of the entry", do((*UI).toggleSplit), "s"),
+				newAction("shrink-list", "make the list narrower than the details", do((*UI).shrinkList), "<"),
+				newAction("grow-list", "make the list wider than the details", do((*UI).growList), ">"),
+				newAction("edit-notes", "edit the notes of the entry", do((*UI).promptNote), "e"),
+				newAction("agenda", "show the open entries of all lists by due date", do((*UI).openAgenda), "A"),
+				newAction("calendar", "show the due dates in a calendar", do((*UI).openCalendar), "C"),
+				newAction("stats", "show statistics about the completed entries", do((*UI).openStats), "S"),
+			},
+			{
+				newAction("switch-list", "switch to list (1-9)", (*UI).switchList, "1", "2", "3", "4", "5", "6", "7", "8", "9"),
+				newAction("toggle", "toggle entry", do((*UI).listMarkEntry), "enter"),
+				newAction("switch-theme", "switch to the next color theme", do((*UI).switchTheme), "T"),
+				newAction("visual", "select a range of entries", do((*UI).enterVisual), "v"),
+				newAction("visual-entry", "select single entries", do((*UI).enterVisualEntry), "V"),
+				newAction("undo", "undo the last change to several entries", do((*UI).undo), "u"),
+				newAction("command", "enter a command", do((*UI).enterCommand), ":"),
+				newAction("exit", "exit", do((*UI).exit), "x"),
+			},
+		}},
+		{editMode, "Edit entry", editorActions(
+			newAction("commit", "save the entry", do((*UI).exitEdit), "enter"),
+			newAction("cancel", "discard the changes", do((*UI).cancelEdit), "esc"),
+		)},
+		{editListNameMode, "Edit list name", editorActions(
+			newAction("commit", "save the name", do((*UI).exitNameEdit), "enter"),
+			newAction("cancel", "discard the changes", do((*UI).cancelNameEdit), "esc"),
+		)},
+		{deleteListMode, "Delete list", [][]*Action{
+			{
+				newAction("confirm", "delete the list", do((*UI).confirmDeleteList), "y"),
+				newAction("cancel", "keep the list", do((*UI).cancelDeleteList), "n", "esc"),
+			},
+		}},
+		{visualMode, "Visual", [][]*Action{
+			{
+				newAction("down", "go one entry down", do((*UI).listDown), "j"),
+				newAction("up", "go one entry up", do((*UI).listUp), "k"),
+				newAction("range", "switch between range and single selection", do((*UI).visualRange), "v"),
+				newAction("pick", "select or unselect the entry", do((*UI).visualPick), "V", "space"),
+			},
+			{
+				newAction("toggle", "toggle the selected entries", do((*UI).visualToggle), "enter"),
+				newAction("delete", "delete the selected entries", do((*UI).visualDelete), "d"),
+				newAction("move", "move the selected entries to another list", do((*UI).promptMove), "m"),
+				newAction("copy", "copy the selected entries to a list", do((*UI).promptCopy), "c"),
+				newAction("yank", "yank the selected entries to the clipboard", do((*UI).visualYank), "y"),
+				newAction("tag", "tag the selected entries", do((*UI).promptTag), "t"),
+				newAction("priority", "set the priority of the selected entries", do((*UI).promptPriority), "!"),
+			},
+			{
+				newAction("cancel", "leave the visual mode", do((*UI).leaveVisual), "esc"),
+			},
+		}},
+		{agendaMode, "Agenda", [][]*Action{
+			{
+				newAction("down", "go one entry down", do((*UI).agendaDown), "j", "down"),
+				newAction("up", "go one entry up", do((*UI).agendaUp), "k", "up"),
+			},
+			{
+				newAction("toggle", "mark the entry done", do((*UI).agendaToggle), "enter"),
+				newAction("edit", "edit the entry", do((*UI).enterEdit), "i"),
+				newAction("edit-notes", "edit the notes of the entry", do((*UI).promptNote), "e"),
+				newAction("due", "set the due date of the entry", do((*UI).promptDue), "@"),
+				newAction("later", "make the entry due one day later", do((*UI).agendaLater), "+"),
+				newAction("earlier", "make the entry due one day earlier", do((*UI).agendaEarlier), "-"),
+			},
+			{
+				newAction("open-list", "go to the entry in its list", do((*UI).agendaOpenList), "o"),
+				newAction("split", "show or hide the details of the entry", do((*UI).toggleSplit), "s"),
+				newAction("undo", "undo the last change", do((*UI).undo), "u"),
+				newAction("command", "enter a command", do((*UI).enterCommand), ":"),
+				newAction("close", "go back to the list", do((*UI).closeAgenda), "esc", "A", "q"),
+			},
+		}},
+		{calendarMode, "Calendar", [][]*Action{
+			{
+				newAction("previous-day", "go one day back", do((*UI).previousDay), "h", "left"),
+				newAction("next-day", "go one day ahead", do((*UI).nextDay), "l", "right"),
+				newAction("previous-week", "go one week back", do((*UI).previousWeek), "k", "up"),
+				newAction("next-week", "go one week ahead", do((*UI).nextWeek), "j", "down"),
+				newAction("previous-month", "go one month back", do((*UI).previousMonth), "[", "pgup"),
+				newAction("next-month", "go one month ahead", do((*UI).nextMonth), "]", "pgdn"),
+				newAction("today", "go to today", do((*UI).calendarToday), "t"),
+			},
+			{
+				newAction("entry-down", "select the next entry of the day", do((*UI).calendarEntryDown), "J", "tab"),
+				newAction("entry-up", "select the previous entry of the day", do((*UI).calendarEntryUp), "K"),
+				newAction("toggle", "toggle the entry", do((*UI).calendarToggle), "enter"),
+				newAction("move", "move the entry to another day, press again on the day", do((*UI).calendarMove), "m"),
+				newAction("edit", "edit the entry", do((*UI).enterEdit), "i"),
+				newAction("edit-notes", "edit the notes of the entry", do((*UI).promptNote), "e"),
+			},
+			{
+				newAction("undo", "undo the last change", do((*UI).undo), "u"),
+				newAction("command", "enter a command", do((*UI).enterCommand), ":"),
+				newAction("close", "go back to the list, or stop moving the entry", do((*UI).calendarCancel), "esc", "C", "q"),
+			},
+		}},
+		{statsMode, "Stats", [][]*Action{
+			{
+				newAction("down", "scroll down", do((*UI).statsDown), "j", "down"),
+				newAction("up", "scroll up", do((*UI).statsUp), "k", "up"),
+				newAction("close", "go back to the list", do((*UI).closeStats), "esc", "S", "q"),
+			},
+		}},
+		{commandMode, "Command", [][]*Action{
+			{
+				newAction("run", "run the command", do((*UI).runCommand), "enter"),
+				newAction("cancel", "leave the command line", do((*UI).cancelCommand), "esc"),
+				newAction("complete", "complete the command or list name", do((*UI).commandComplete), "tab"),
+				newAction("history-prev", "previous command", do((*UI).commandHistoryPrev), "up"),
+				newAction("history-next", "next command", do((*UI).commandHistoryNext), "down"),
+				newAction("delete-rune", "delete the last character", do((*UI).commandDeleteRune), "backspace"),
+			},
+		}},
+		{helpMode, "Help", [][]*Action{
+			{
+				newAction("close", "close the help", do((*UI).closeHelp), "esc", "q", "?"),
+				newAction("down", "scroll down", do((*UI).helpDown), "j", "down"),
+				newAction("up", "scroll up", do((*UI).helpUp), "k", "up"),
+			},
+		}},
+	})
+}
+
+// withHelp adds the help action to the last group of every section but the
+// help itself. Where text is typed only f1 opens it.
+func withHelp(sections []KeymapSection) []KeymapSection {
+	for i, s := range sections {
+		keys := []string{"?", "f1"}
+		switch s.mode {
+		case helpMode:
+			continue
+		case editMode, editListNameMode, commandMode:
+			keys = []string{"f1"}
+		}
+		last := len(s.groups) - 1
+		sections[i].groups[last] = append(s.groups[last], newAction("help", "show the keys of the current mode", do((*UI).openHelp), keys...))
+	}
+	return sections
+}
+
+// editorActions returns the actions of an editor mode. Every mode gets its
+// own actions so they can be rebound separately.
+func editorActions(commit, cancel *Action) [][]*Action {
+	return [][]*Action{
+		{
+			commit,
+			cancel,
+		},
+		{
+			newAction("cursor-left", "move the cursor left", onEditor((*lineEditor).left), "left", "ctrl-b"),
+			newAction("cursor-right", "move the cursor right", onEditor((*lineEditor).right), "right", "ctrl-f"),
+			newAction("word-left", "move the cursor to the previous word", onEditor((*lineEditor).wordLeft), "ctrl-left", "alt-b"),
+			newAction("word-right", "move the cursor to the end of the word", onEditor((*lineEditor).wordRight), "ctrl-right", "alt-f"),
+			newAction("line-start", "move the cursor to the start", onEditor((*lineEditor).home), "home", "ctrl-a"),
+			newAction("line-end", "move the cursor to the end", onEditor((*lineEditor).end), "end", "ctrl-e"),
+		},
+		{
+			newAction("delete-rune", "delete the character before the cursor", onEditor((*lineEditor).deleteBack), "backspace"),
+			newAction("delete-forward", "delete the character under the cursor", onEditor((*lineEditor).deleteForward), "delete", "ctrl-d"),
+			newAction("kill-word", "cut the word before the cursor", kill((*lineEditor).killWordBack), "ctrl-w"),
+			newAction("kill-start", "cut the text before the cursor", kill((*lineEditor).killToStart), "ctrl-u"),
+			newAction("kill-end", "cut the text after the cursor", kill((*lineEditor).killToEnd), "ctrl-k"),
+			newAction("yank", "paste the last cut text", do((*UI).yankKill), "ctrl-y"),
+			newAction("yank-pop", "replace the pasted text by the one cut before", do((*UI).yankPop), "alt-y"),
+		},
+	}
+}
+
+// bindings maps the key names of every mode to their action.
+var bindings map[Mode]map[string]binding
+
+type binding struct {
+	action *Action
+	index  int
+}
+
+func init() {
+	keymaps = defaultKeymaps()
+	if err := bindKeys(nil); err != nil {
+		panic(err)
+	}
+}
+
+func newAction(name string, text string, run func(ui *UI, i int), keys ...string) *Action {
+	return &Action{name: name, text: text, keys: keys, run: run}
+}
+
+func do(f func(ui *UI)) func(ui *UI, i int) {
+	return func(ui *UI, i int) { f(ui) }
+}
+
+func onEditor(f func(e *lineEditor)) func(ui *UI, i int) {
+	return func(ui *UI, i int) {
+		f(&ui.editor)
+		ui.syncEdit()
+		ui.yanked = ""
+	}
+}
+
+func kill(f func(e *lineEditor) string) func(ui *UI, i int) {
+	return func(ui *UI, i int) {
+		ui.addKill(f(&ui.editor))
+		ui.syncEdit()
+		ui.yanked = ""
+	}
+}
+
+// bindKeys rebinds the actions named in overrides and rebuilds the bindings.
+func bindKeys(overrides map[Mode]map[string][]string) error {
+	for _, section := range keymaps {
+		for name := range overrides[section.mode] {
+			if section.action(name) == nil {
+				return fmt.Errorf("[keys] unknown %s action %q", strings.ToLower(section.title), name)
+			}
+		}
+		for name, keys := range overrides[section.mode] {
+			section.action(name).keys = keys
+		}
+	}
+
+	b := make(map[Mode]map[string]binding)
+	for _, section := range keymaps {
+		b[section.mode] = make(map[string]binding)
+		for _, group := range section.groups {
+			for _, a := range group {
+				for i, key := range a.keys {
+					if other, ok := b[section.mode][key]; ok {
+						return fmt.Errorf("[keys] %s is bound to both %s and %s", key, other.action.name, a.name)
+					}
+					b[section.mode][key] = binding{action: a, index: i}
+				}
+			}
+		}
+	}
+	bindings = b
+	return nil
+}
+
+func (s KeymapSection) action(name string) *Action {
+	for _, group := range s.groups {
+		for _, a := range group {
+			if a.name == name {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// runAction runs the action bound to the key in the current mode and reports
+// whether there was one.
+func runAction(ui *UI, ev *tcell.EventKey) bool {
+	b, ok := bindings[ui.mode][keyName(ev)]
+	if !ok {
+		return false
+	}
+	b.action.run(ui, b.index)
+	return true
+}
+
+// keyName returns the name a key is bound by in the config file, e.g. "j",
+// "enter", "ctrl-a" or "alt-left".
+func keyName(ev *tcell.EventKey) string {
+	var name string
+	switch ev.Key() {
+	case tcell.KeyRune:
+		if ev.Rune() == ' ' {
+			name = "space"
 		} else {
+			name = string(ev.Rune())
+		}
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		name = "backspace"
+	case tcell.KeyEscape:
+		name = "esc"
+	case tcell.KeyEnter:
+		name = "enter"
+	case tcell.KeyTab:
+		name = "tab"
+	default:
+		name = strings.ToLower(ev.Name())
+		name = strings.ReplaceAll(name, "+", "-")
+		return name
+	}
+	if ev.Modifiers()&tcell.ModAlt != 0 {
+		name = "alt-" + name
+	}
+	return name
+}
+
+// actionKey returns the keys of the named action for hints in the UI.
+func actionKey(mode Mode, name string) string {
+	if section, ok := sectionByMode(mode); ok {
+		if a := section.action(name); a != nil {
+			return a.keyText()
+		}
+	}
+	return ""
+}
+
+func (a *Action) keyText() string {
+	if len(a.keys) > 3 {
+		return a.keys[0] + "-" + a.keys[len(a.keys)-1]
+	}
+	return strings.Join(a.keys, "/")
+}
+
+// lines returns the overview of the section, one line per action and an
+// empty line between groups.
+func (s KeymapSection) lines() []string {
+	var lines []string
+	for i, group := range s.groups {
+		if i != 0 {
+			lines = append(lines, "")
+		}
+		for _, a := range group {
+			lines = append(lines, fmt.Sprintf("%-10s %s", a.keyText(), a.text))
+		}
+	}
+	return lines
+}
+
+func sectionByMode(mode Mode) (KeymapSection, bool) {
+	for _, section := range keymaps {
+		if section.mode == mode {
+			return section, true
+		}
+	}
+	return KeymapSection{}, false
+}
+
+func printKeymaps() {
+	for i, section := range keymaps {
+		if i != 0 {
 			fmt.Printf("\n")
-    }
+		}
+		fmt.Printf("%s\n\n", section.title)
+		for _, line := range section.lines() {
+			fmt.Println(line)
+		}
 	}
 }
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Encrypted values are stored as encPrefix followed by the base64 encoded
+// nonce and ciphertext. Values without the prefix are plain text.
+const encPrefix = "enc:v1:"
+const verifierText = "todo"
+
+var errLocked = errors.New("the database is encrypted, a passphrase is required")
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+type fieldCipher struct {
+	aead cipher.AEAD
+}
+
+func newFieldCipher(passphrase string, salt []byte) (*fieldCipher, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{aead: aead}, nil
+}
+
+func (c *fieldCipher) encrypt(s string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(s), nil)
+	return encPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *fieldCipher) decrypt(s string) (string, error) {
+	if !strings.HasPrefix(s, encPrefix) {
+		return s, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encPrefix))
+	if err != nil {
+		return "", err
+	}
+	n := c.aead.NonceSize()
+	if len(sealed) < n {
+		return "", errors.New("encrypted value is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// seal encrypts a value before it is written if the database is encrypted.
+func (db *DB) seal(s string) (string, error) {
+	if db.cipher == nil {
+		return s, nil
+	}
+	return db.cipher.encrypt(s)
+}
+
+// unseal decrypts a value that was read from the database.
+func (db *DB) unseal(s string) (string, error) {
+	if !strings.HasPrefix(s, encPrefix) {
+		return s, nil
+	}
+	if db.cipher == nil {
+		return "", errLocked
+	}
+	return db.cipher.decrypt(s)
+}
+
+func (db *DB) encrypted() (bool, error) {
+	var n int
+	if err := db.db.QueryRow("SELECT COUNT(*) FROM crypt").Scan(&n); err != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+func (db *DB) locked() bool {
+	encrypted, err := db.encrypted()
+	return err == nil && encrypted && db.cipher == nil
+}
+
+func (db *DB) unlock(passphrase string) error {
+	var salt []byte
+	var verifier string
+	row := db.db.QueryRow("SELECT salt, verifier FROM crypt WHERE id = 1")
+	if err := row.Scan(&salt, &verifier); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	c, err := newFieldCipher(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	if text, err := c.decrypt(verifier); err != nil || text != verifierText {
+		return errWrongPassphrase
+	}
+	db.cipher = c
+	return nil
+}
+
+// setPassphrase re-encrypts every list name and entry with a key derived
+// from passphrase, or decrypts them if passphrase is empty. The database has
+// to be unlocked.
+func (db *DB) setPassphrase(passphrase string) error {
+	if db.locked() {
+		return errLocked
+	}
+	var next *fieldCipher
+	var salt []byte
+	if passphrase != "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		c, err := newFieldCipher(passphrase, salt)
+		if err != nil {
+			return err
+		}
+		next = c
+	}
+
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, column := range [][2]string{{"list", "name"}, {"item", "content"}, {"item", "tags"}, {"item", "notes"}, {"history", "text"}, {"smart_list", "name"}, {"smart_list", "query"}} {
+		if err := db.recrypt(tx, column[0], column[1], next); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM crypt"); err != nil {
+		return err
+	}
+	if next != nil {
+		verifier, err := next.encrypt(verifierText)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO crypt (id, salt, verifier) VALUES (1, ?, ?)", salt, verifier); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.cipher = next
+	return db.compact()
+}
+
+// compact rewrites the database file and empties the WAL, so no freed page
+// or old WAL frame keeps the data as it was before re-encrypting it.
+func (db *DB) compact() error {
+	if err := db.checkpoint(); err != nil {
+		return err
+	}
+	if _, err := db.db.Exec("VACUUM"); err != nil {
+		return err
+	}
+	return db.checkpoint()
+}
+
+func (db *DB) checkpoint() error {
+	var busy, frames, checkpointed int
+	row := db.db.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)")
+	if err := row.Scan(&busy, &frames, &checkpointed); err != nil {
+		return err
+	}
+	if busy != 0 {
+		return errors.New("another instance is using the database, close it and run the command again to remove the old data")
+	}
+	return nil
+}
+
+func (db *DB) recrypt(tx *sql.Tx, table, column string, next *fieldCipher) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT id, %s FROM %s", column, table))
+	if err != nil {
+		return err
+	}
+	values := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var value string
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		values[id] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, value := range values {
+		plain, err := db.unseal(value)
+		if err != nil {
+			return err
+		}
+		if next != nil {
+			if value, err = next.encrypt(plain); err != nil {
+				return err
+			}
+		} else {
+			value = plain
+		}
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", table, column), value, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// passphraseFromEnv reads the passphrase from $TODO_PASSPHRASE or from the
+// first line of the keyring file at $TODO_KEYFILE (default
+// ~/.todo_data/keyfile).
+func passphraseFromEnv() (string, bool) {
+	if p, ok := os.LookupEnv("TODO_PASSPHRASE"); ok {
+		return p, true
+	}
+	file := os.Getenv("TODO_KEYFILE")
+	if file == "" {
+		dir, err := dataDir()
+		if err != nil {
+			return "", false
+		}
+		file = path.Join(dir, "keyfile")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+// openUnlockedDatabase opens the database for the commands that run
+// without the TUI.
+func openUnlockedDatabase() (*DB, error) {
+	db, err := newDatabase()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.init(); err != nil {
+		db.close()
+		return nil, err
+	}
+	if encrypted, err := db.encrypted(); err != nil || !encrypted {
+		return db, err
+	}
+	passphrase, ok := passphraseFromEnv()
+	if !ok {
+		if passphrase, err = readPassphrase("Passphrase: "); err != nil {
+			db.close()
+			return nil, err
+		}
+	}
+	if err := db.unlock(passphrase); err != nil {
+		db.close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func readPassphrase(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errLocked
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	p, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(p), err
+}
+
+func readNewPassphrase() (string, error) {
+	p, ok := os.LookupEnv("TODO_NEW_PASSPHRASE")
+	if !ok {
+		var err error
+		if p, err = readPassphrase("New passphrase: "); err != nil {
+			return "", err
+		}
+	}
+	if p == "" {
+		return "", errors.New("the passphrase must not be empty")
+	}
+	if ok {
+		return p, nil
+	}
+	repeated, err := readPassphrase("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p != repeated {
+		return "", errors.New("the passphrases do not match")
+	}
+	return p, nil
+}
+
+func runEncrypt(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: todo encrypt enable|disable|rotate")
+	}
+	db, err := openUnlockedDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.close()
+	encrypted, err := db.encrypted()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "enable", "rotate":
+		if args[0] == "enable" && encrypted {
+			return errors.New("the database is already encrypted, use rotate to change the passphrase")
+		}
+		if args[0] == "rotate" && !encrypted {
+			return errors.New("the database is not encrypted")
+		}
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := db.setPassphrase(passphrase); err != nil {
+			return err
+		}
+		fmt.Println("the database is encrypted")
+		// The snapshots hold the data unencrypted or under the old key.
+		replaced, err := db.replaceSnapshots()
+		if err != nil {
+			return fmt.Errorf("the old snapshots could not be replaced, delete them by hand: %w", err)
+		}
+		if replaced != 0 {
+			fmt.Printf("replaced %d snapshots with a new one\n", replaced)
+		}
+	case "disable":
+		if !encrypted {
+			return errors.New("the database is not encrypted")
+		}
+		if err := db.setPassphrase(""); err != nil {
+			return err
+		}
+		fmt.Println("the database is no longer encrypted")
+	default:
+		return errors.New("usage: todo encrypt enable|disable|rotate")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEncryptLeavesNoPlaintext checks that neither the database file, its
+// WAL nor the snapshots keep the data as it was before encrypting it.
+func TestEncryptLeavesNoPlaintext(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	file := filepath.Join(t.TempDir(), "data.db")
+	db, err := openDatabase(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.close()
+	if err := db.init(); err != nil {
+		t.Fatal(err)
+	}
+	secret := "a rather secret entry"
+	id := mustItem(t, db, mustList(t, db))
+	if _, err := db.updateItemContent(id, secret, -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.snapshot(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.setPassphrase("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.replaceSnapshots(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{file, file + "-wal"}
+	names, err := snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("snapshots = %v, want a single new one", names)
+	}
+	dir, _ := backupDir()
+	for _, name := range names {
+		files = append(files, filepath.Join(dir, name))
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("%s still holds the entry unencrypted", filepath.Base(f))
+		}
+	}
+
+	item, _, err := db.getItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.content != secret {
+		t.Errorf("content = %q, want %q", item.content, secret)
+	}
+}
@@ -3,33 +3,60 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// historyRetention is how long the history of a deleted entry is kept.
+const historyRetention = 7 * 24 * time.Hour
+
 type DB struct {
-	db *sql.DB
+	db               *sql.DB
+	listOrderVersion int
+	cipher           *fieldCipher
 }
 
+// errConflict is returned by the versioned writes when the row was changed
+// by another process since it was read.
+var errConflict = errors.New("changed by another process")
+
 func newDatabase() (*DB, error) {
-    homeDir, err := os.UserHomeDir()
-    if err != nil {
-        return nil, err
-    }
-	os.Mkdir(path.Join(homeDir, ".todo_data"), os.ModePerm)
-	db, err := sql.Open("sqlite3", path.Join(homeDir, ".todo_data/data.db"))
+	dir, err := dataDir()
 	if err != nil {
 		return nil, err
 	}
-	q := `
-  PRAGMA foreign_keys = ON;
-  `
-	_, err = db.Exec(q)
+	return openDatabase(path.Join(dir, "data.db"))
+}
+
+func dataDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := path.Join(homeDir, ".todo_data")
+	os.Mkdir(dir, os.ModePerm)
+	return dir, nil
+}
+
+func openDatabase(file string) (*DB, error) {
+	dsn := file + "?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
+	// A single connection makes PRAGMA data_version report only the
+	// changes committed by other processes, see watch.go.
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
 	return &DB{db: db}, nil
 }
 
@@ -50,17 +77,96 @@ func (db *DB) init() error {
 	if err != nil {
 		return err
 	}
+	_, err = db.db.Exec("CREATE TABLE IF NOT EXISTS crypt (id INTEGER, salt BLOB, verifier TEXT, UNIQUE(id))")
+	if err != nil {
+		return err
+	}
+	// The history of deleted entries is kept for a while so undo, also in
+	// another running instance, can bring it back with the entry.
+	_, err = db.db.Exec("CREATE TABLE IF NOT EXISTS history (id INTEGER PRIMARY KEY ASC, item_id INTEGER, at INTEGER, text TEXT)")
+	if err != nil {
+		return err
+	}
+	_, err = db.db.Exec(`DELETE FROM history WHERE item_id NOT IN (SELECT id FROM item)
+		AND item_id NOT IN (SELECT item_id FROM history WHERE at > ?)`, now()-int64(historyRetention.Seconds()))
+	if err != nil {
+		return err
+	}
+	_, err = db.db.Exec("CREATE TABLE IF NOT EXISTS smart_list (id INTEGER PRIMARY KEY ASC, name TEXT, query TEXT)")
+	if err != nil {
+		return err
+	}
+	if err := db.addColumn("ui", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("list", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("list", "order_version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "tags", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "priority", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "notes", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "due", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "created_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("item", "done_at", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("list", "done_view", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := db.addColumn("list", "sort_order", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
 	return nil
 }
 
+func (db *DB) addColumn(table, column, definition string) error {
+	columns, err := tableColumns(db.db, table)
+	if err != nil {
+		return err
+	}
+	if columns[column] {
+		return nil
+	}
+	_, err = db.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (db *DB) close() {
 	db.db.Close()
 }
 
+func (db *DB) dataVersion() (int, error) {
+	var version int
+	if err := db.db.QueryRow("PRAGMA data_version").Scan(&version); err != nil {
+		return -1, err
+	}
+	return version, nil
+}
+
 func (db *DB) createList() (int, error) {
 	var id int
-	row := db.db.QueryRow("INSERT INTO list (id, name, item_order) VALUES (null, 'List name', '') RETURNING id")
-	err := row.Scan(&id)
+	name, err := db.seal("List name")
+	if err != nil {
+		return -1, err
+	}
+	row := db.db.QueryRow("INSERT INTO list (id, name, item_order) VALUES (null, ?, '') RETURNING id", name)
+	err = row.Scan(&id)
 	if err != nil {
 		return -1, err
 	}
@@ -75,152 +181,628 @@ func (db *DB) deleteList(id int) error {
 	return nil
 }
 
-func (db *DB) updateListName(name string, id int) error {
-	_, err := db.db.Exec("UPDATE list SET name = ? WHERE id = ?", name, id)
+// updateListName writes the name if the list is still at the given version
+// and returns the new version. A negative version overwrites unconditionally.
+func (db *DB) updateListName(name string, id int, version int) (int, error) {
+	sealed, err := db.seal(name)
 	if err != nil {
-		return err
+		return -1, err
 	}
-	return nil
+	return db.versionedUpdate("list", map[string]any{"name": sealed}, id, version)
+}
+
+func (db *DB) updateListDoneView(id int, v DoneView) error {
+	_, err := db.db.Exec("UPDATE list SET done_view = ? WHERE id = ?", v, id)
+	return err
+}
+
+// updateListSortOrder stores how the list is kept sorted, an empty order
+// turns that off.
+func (db *DB) updateListSortOrder(id int, o sortOrder) error {
+	var s string
+	if o.key != "" {
+		s = o.String()
+	}
+	_, err := db.db.Exec("UPDATE list SET sort_order = ? WHERE id = ?", s, id)
+	return err
 }
 
 func (db *DB) getLists() ([]List, error) {
-	rows, err := db.db.Query("SELECT id, name from list")
+	rows, err := db.db.Query("SELECT id, name, version, order_version, item_order, done_view, sort_order from list")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var lists []List
+	var orders []string
 	for rows.Next() {
-		var id int
-		var name string
-		if err := rows.Scan(&id, &name); err != nil {
+		var list List
+		var order, sortOrder string
+		if err := rows.Scan(&list.ID, &list.name, &list.version, &list.orderVersion, &order, &list.doneView, &sortOrder); err != nil {
 			return nil, err
 		}
-		items, _ := db.getItems(id)
-		list := List{
-			ID:    id,
-			name:  name,
-			items: items,
+		if sortOrder != "" {
+			// An order that can't be read leaves the list unsorted.
+			list.sortOrder, _ = parseSortOrder(sortOrder)
+		}
+		if list.name, err = db.unseal(list.name); err != nil {
+			return nil, err
 		}
 		lists = append(lists, list)
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The items are queried after the rows are closed, the database only
+	// ever hands out a single connection.
+	for i := range lists {
+		items, err := db.getItems(lists[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		lists[i].items = orderItems(items, orders[i])
 	}
 	return lists, nil
 }
 
+func orderItems(items []Item, stored string) []Item {
+	var ids []int
+	for _, item := range items {
+		ids = append(ids, item.id)
+	}
+	ids, err := applyOrder(stored, ids)
+	if err != nil {
+		return items
+	}
+	var orderedItems []Item
+	for _, id := range ids {
+		for _, item := range items {
+			if item.id == id {
+				orderedItems = append(orderedItems, item)
+			}
+		}
+	}
+	return orderedItems
+}
+
 func (db *DB) createItem(listID int) (int, error) {
 	var id int
-	row := db.db.QueryRow("INSERT INTO item (content, done, list_id) VALUES ('New Entry', ?, ?) RETURNING id", 0, listID)
-	err := row.Scan(&id)
+	content, err := db.seal("New Entry")
 	if err != nil {
 		return -1, err
 	}
-	return id, nil
+	row := db.db.QueryRow("INSERT INTO item (content, done, list_id, created_at) VALUES (?, ?, ?, ?) RETURNING id", content, 0, listID, now())
+	err = row.Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+	return id, db.logHistory(db.db, id, "created")
 }
 
+// deleteItem deletes the entry and takes it out of the stored order of its
+// list in one transaction.
 func (db *DB) deleteItem(id int) error {
-	_, err := db.db.Exec("DELETE FROM item WHERE id = ?", id)
+	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+	if err := db.removeItem(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (db *DB) updateItemContent(id int, content string) error {
-	_, err := db.db.Exec("UPDATE item SET content = ? WHERE id = ?", content, id)
+// removeItem deletes the entry and rewrites the stored order of its list
+// without it. The order version stays, no entry changed its place. An entry
+// that is already gone is left alone. The deletion is logged, the history
+// outlives the entry by historyRetention.
+func (db *DB) removeItem(tx *sql.Tx, id int) error {
+	var listID int
+	var stored string
+	row := tx.QueryRow("SELECT list.id, list.item_order FROM item JOIN list ON list.id = item.list_id WHERE item.id = ?", id)
+	if err := row.Scan(&listID, &stored); errors.Is(err, sql.ErrNoRows) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM item WHERE id = ?", id); err != nil {
+		return err
+	}
+	if err := db.logHistory(tx, id, "deleted"); err != nil {
+		return err
+	}
+	remaining, err := queryIDs(tx, "SELECT id FROM item WHERE list_id = ?", listID)
 	if err != nil {
 		return err
 	}
-	return nil
+	ids, err := applyOrder(stored, remaining)
+	if err != nil {
+		// An order that can't be read is replaced.
+		ids = remaining
+	}
+	order, err := encodeOrder(ids)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE list SET item_order = ? WHERE id = ?", order, listID)
+	return err
 }
 
-func (db *DB) updateItemDone(id int, done bool) error {
+// updateItemContent writes the content if the entry is still at the given
+// version and returns the new version. A negative version overwrites
+// unconditionally.
+func (db *DB) updateItemContent(id int, content string, version int) (int, error) {
+	sealed, err := db.seal(content)
+	if err != nil {
+		return -1, err
+	}
+	version, err = db.versionedUpdate("item", map[string]any{"content": sealed}, id, version)
+	if err != nil {
+		return version, err
+	}
+	return version, db.logHistory(db.db, id, "edited")
+}
+
+// versionedUpdate sets the columns to their values if the row is still at
+// the given version and returns the new version.
+func (db *DB) versionedUpdate(table string, values map[string]any, id int, version int) (int, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	defer tx.Rollback()
+	var current int
+	row := tx.QueryRow(fmt.Sprintf("SELECT version FROM %s WHERE id = ?", table), id)
+	if err := row.Scan(&current); err != nil {
+		return -1, err
+	}
+	if version >= 0 && current != version {
+		return current, errConflict
+	}
+	columns := make([]string, 0, len(values))
+	for column := range values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	var args []any
+	for i, column := range columns {
+		args = append(args, values[column])
+		columns[i] += " = ?"
+	}
+	args = append(args, current+1, id)
+	q := fmt.Sprintf("UPDATE %s SET %s, version = ? WHERE id = ?", table, strings.Join(columns, ", "))
+	if _, err := tx.Exec(q, args...); err != nil {
+		return -1, err
+	}
+	return current + 1, tx.Commit()
+}
+
+// updateItemDone marks the entry done or open like updateItemContent writes
+// the content.
+func (db *DB) updateItemDone(id int, done bool, version int) (int, error) {
 	var newDone int
 	if done {
 		newDone = 1
 	} else {
 		newDone = 0
 	}
-	_, err := db.db.Exec("UPDATE item SET done = ? WHERE id = ?", newDone, id)
+	version, err := db.versionedUpdate("item", map[string]any{"done": newDone, "done_at": doneAt(done)}, id, version)
+	if err != nil {
+		return version, err
+	}
+	return version, db.logHistory(db.db, id, doneText(done))
+}
+
+// updateItems sets column to value for all ids in one transaction.
+func (db *DB) updateItems(ids []int, column string, value any) error {
+	tx, err := db.db.Begin()
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+	q := fmt.Sprintf("UPDATE item SET %s = ?, version = version + 1 WHERE id = ?", column)
+	text, err := db.historyText(tx, column, value)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := tx.Exec(q, value, id); err != nil {
+			return err
+		}
+		if done, ok := value.(bool); ok && column == "done" {
+			if _, err := tx.Exec("UPDATE item SET done_at = ? WHERE id = ?", doneAt(done), id); err != nil {
+				return err
+			}
+		}
+		if err := db.logHistory(tx, id, text); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyItems adds copies of the entries to the list in one transaction and
+// returns their ids.
+func (db *DB) copyItems(ids []int, listID int) ([]int, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var copies []int
+	for _, id := range ids {
+		var copy int
+		row := tx.QueryRow(`INSERT INTO item (content, done, list_id, tags, priority, notes, due, created_at, done_at)
+			SELECT content, done, ?, tags, priority, notes, due, ?, done_at FROM item WHERE id = ? RETURNING id`, listID, now(), id)
+		if err := row.Scan(&copy); err != nil {
+			return nil, err
+		}
+		if err := db.logHistory(tx, copy, "created as a copy"); err != nil {
+			return nil, err
+		}
+		copies = append(copies, copy)
+	}
+	return copies, tx.Commit()
+}
+
+// insertItems adds the entries to the list in one transaction and returns
+// their ids.
+func (db *DB) insertItems(listID int, items []Item) ([]int, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var ids []int
+	for _, item := range items {
+		content, err := db.seal(item.content)
+		if err != nil {
+			return nil, err
+		}
+		tags, err := db.seal(strings.Join(item.tags, " "))
+		if err != nil {
+			return nil, err
+		}
+		var id int
+		row := tx.QueryRow("INSERT INTO item (content, done, list_id, tags, priority, created_at, done_at) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id",
+			content, item.done, listID, tags, item.priority, now(), doneAt(item.done))
+		if err := row.Scan(&id); err != nil {
+			return nil, err
+		}
+		if err := db.logHistory(tx, id, "pasted"); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, tx.Commit()
+}
+
+func (db *DB) deleteItems(ids []int) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range ids {
+		if err := db.removeItem(tx, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// setItemTags stores the tags of every entry in tags in one transaction.
+func (db *DB) setItemTags(tags map[int][]string) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, t := range tags {
+		sealed, err := db.seal(strings.Join(t, " "))
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE item SET tags = ?, version = version + 1 WHERE id = ?", sealed, id); err != nil {
+			return err
+		}
+		if err := db.logHistory(tx, id, tagsText(t)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *DB) unsealTags(sealed string) ([]string, error) {
+	tags, err := db.unseal(sealed)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(tags), nil
 }
 
 func (db *DB) getItems(listID int) ([]Item, error) {
-	rows, err := db.db.Query("SELECT id, content, done FROM item WHERE list_id = ?", listID)
+	rows, err := db.db.Query("SELECT "+itemColumns+" FROM item WHERE list_id = ?", listID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 	var items []Item
 	for rows.Next() {
-		var id int
-		var content string
-		var done int
-		if err := rows.Scan(&id, &content, &done); err != nil {
+		item, done, err := db.scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
-		items = append(items, Item{id: id, content: content, done: done == 1})
+		item.done = done == 1
+		items = append(items, item)
 	}
 	return items, nil
 }
 
-func (db *DB) saveOrder(lists []List) error {
-	listOrder := make(map[int]int)
-	for i, l := range lists {
-		listOrder[i] = l.ID
-		items := l.items
-		order := make(map[int]int)
-		for j, item := range items {
-			order[j] = item.id
+func (db *DB) getItem(id int) (Item, int, error) {
+	var listID int
+	if err := db.db.QueryRow("SELECT list_id FROM item WHERE id = ?", id).Scan(&listID); err != nil {
+		return Item{}, -1, err
+	}
+	row := db.db.QueryRow("SELECT "+itemColumns+" FROM item WHERE id = ?", id)
+	item, done, err := db.scanItem(row)
+	if err != nil {
+		return Item{}, -1, err
+	}
+	item.done = done == 1
+	return item, listID, nil
+}
+
+// saveItemOrder stores the order of the entries of l. If the order was
+// changed by another process since it was read, the two orders are merged:
+// entries known to l keep the order of l, entries only known to the database
+// are appended. The returned bool reports whether such a merge happened.
+func (db *DB) saveItemOrder(l *List) (bool, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var version int
+	var stored string
+	row := tx.QueryRow("SELECT order_version, item_order FROM list WHERE id = ?", l.ID)
+	if err := row.Scan(&version, &stored); err != nil {
+		return false, err
+	}
+	var ids []int
+	for _, item := range l.items {
+		ids = append(ids, item.id)
+	}
+	conflict := version != l.orderVersion
+	if conflict {
+		existing, err := queryIDs(tx, "SELECT id FROM item WHERE list_id = ?", l.ID)
+		if err != nil {
+			return false, err
 		}
-		orderString, err := json.Marshal(order)
+		theirs, err := applyOrder(stored, existing)
 		if err != nil {
-			return err
+			return false, err
+		}
+		ids = mergeOrder(ids, theirs)
+	}
+	orderString, err := encodeOrder(ids)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec("UPDATE list SET item_order = ?, order_version = ? WHERE id = ?", orderString, version+1, l.ID)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	l.orderVersion = version + 1
+	return conflict, nil
+}
+
+// saveListOrder stores the order of the lists, merging it with the stored
+// order like saveItemOrder if another process changed it in the meantime.
+func (db *DB) saveListOrder(lists []List) (bool, error) {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var version int
+	var stored string
+	row := tx.QueryRow("SELECT version, list_order FROM ui WHERE id = 1")
+	if err := row.Scan(&version, &stored); err != nil {
+		return false, err
+	}
+	var ids []int
+	for _, l := range lists {
+		ids = append(ids, l.ID)
+	}
+	conflict := version != db.listOrderVersion
+	if conflict {
+		existing, err := queryIDs(tx, "SELECT id FROM list")
+		if err != nil {
+			return false, err
 		}
-		_, err = db.db.Exec("UPDATE list SET item_order = ? WHERE id = ?", string(orderString), l.ID)
+		theirs, err := applyOrder(stored, existing)
 		if err != nil {
-			return err
+			return false, err
 		}
+		ids = mergeOrder(ids, theirs)
 	}
-	listOrderString, err := json.Marshal(listOrder)
+	orderString, err := encodeOrder(ids)
 	if err != nil {
-		return err
+		return false, err
 	}
-	_, err = db.db.Exec("UPDATE ui SET list_order = ? WHERE id = ?", string(listOrderString), 1)
+	_, err = tx.Exec("UPDATE ui SET list_order = ?, version = ? WHERE id = 1", orderString, version+1)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	db.listOrderVersion = version + 1
+	return conflict, nil
 }
 
-func (db *DB) loadListOrder() (map[int]int, error) {
-	row := db.db.QueryRow("SELECT list_order FROM ui WHERE id = 1")
-	var orderString string
-	if err := row.Scan(&orderString); err != nil {
+func queryIDs(tx *sql.Tx, q string, args ...any) ([]int, error) {
+	rows, err := tx.Query(q, args...)
+	if err != nil {
 		return nil, err
 	}
-	if len(orderString) == 0 {
-		return nil, nil
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func encodeOrder(ids []int) (string, error) {
+	order := make(map[int]int)
+	for i, id := range ids {
+		order[i] = id
+	}
+	orderString, err := json.Marshal(order)
+	if err != nil {
+		return "", err
 	}
+	return string(orderString), nil
+}
+
+// applyOrder sorts ids by the stored order string, ids missing from it are
+// appended.
+func applyOrder(stored string, ids []int) ([]int, error) {
 	var order map[int]int
-	if err := json.Unmarshal([]byte(orderString), &order); err != nil {
-		return nil, err
+	if len(stored) != 0 {
+		if err := json.Unmarshal([]byte(stored), &order); err != nil {
+			return nil, err
+		}
+	}
+	exists := make(map[int]bool)
+	for _, id := range ids {
+		exists[id] = true
+	}
+	var sorted []int
+	placed := make(map[int]bool)
+	for i := 0; i < len(order); i++ {
+		if id := order[i]; exists[id] && !placed[id] {
+			sorted = append(sorted, id)
+			placed[id] = true
+		}
 	}
-	return order, nil
+	for _, id := range ids {
+		if !placed[id] {
+			sorted = append(sorted, id)
+		}
+	}
+	return sorted, nil
+}
+
+func mergeOrder(ours, theirs []int) []int {
+	inTheirs := make(map[int]bool)
+	for _, id := range theirs {
+		inTheirs[id] = true
+	}
+	var merged []int
+	inMerged := make(map[int]bool)
+	for _, id := range ours {
+		if inTheirs[id] {
+			merged = append(merged, id)
+			inMerged[id] = true
+		}
+	}
+	for _, id := range theirs {
+		if !inMerged[id] {
+			merged = append(merged, id)
+		}
+	}
+	return merged
 }
 
-func (db *DB) loadItemOrder(listId int) (map[int]int, error) {
-	row := db.db.QueryRow("SELECT item_order FROM list WHERE id = ?", listId)
-	var orderString string
-	if err := row.Scan(&orderString); err != nil {
+func (db *DB) loadLists() ([]List, error) {
+	lists, err := db.getLists()
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	var stored string
+	row := db.db.QueryRow("SELECT version, list_order FROM ui WHERE id = 1")
+	if err := row.Scan(&version, &stored); err != nil {
 		return nil, err
 	}
-	if len(orderString) == 0 {
-		return nil, nil
+	var ids []int
+	for _, l := range lists {
+		ids = append(ids, l.ID)
 	}
-	var order map[int]int
-	if err := json.Unmarshal([]byte(orderString), &order); err != nil {
+	ids, err = applyOrder(stored, ids)
+	if err != nil {
 		return nil, err
 	}
-	return order, nil
+	var orderedLists []List
+	for _, id := range ids {
+		for j := range lists {
+			if lists[j].ID == id {
+				orderedLists = append(orderedLists, lists[j])
+			}
+		}
+	}
+	db.listOrderVersion = version
+	return orderedLists, nil
+}
+
+// itemColumns are the columns scanItem reads.
+const itemColumns = "id, content, done, version, tags, priority, notes, due, created_at, done_at"
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (db *DB) scanItem(row scanner) (Item, int, error) {
+	var item Item
+	var done int
+	var tags string
+	var created, doneAt int64
+	if err := row.Scan(&item.id, &item.content, &done, &item.version, &tags, &item.priority, &item.notes, &item.due, &created, &doneAt); err != nil {
+		return Item{}, 0, err
+	}
+	var err error
+	if item.content, err = db.unseal(item.content); err != nil {
+		return Item{}, 0, err
+	}
+	if item.notes, err = db.unseal(item.notes); err != nil {
+		return Item{}, 0, err
+	}
+	if item.tags, err = db.unsealTags(tags); err != nil {
+		return Item{}, 0, err
+	}
+	item.created = unixTime(created)
+	item.doneAt = unixTime(doneAt)
+	return item, done, nil
+}
+
+// doneAt is the done_at value stored for an entry marked done or not.
+func doneAt(done bool) int64 {
+	if done {
+		return now()
+	}
+	return 0
+}
+
+func now() int64 {
+	return time.Now().Unix()
+}
+
+// unixTime returns the zero time for 0, which marks an unknown time.
+func unixTime(t int64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t, 0)
 }
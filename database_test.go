@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// openTwice opens two handles on one database file, like two instances of
+// the app would.
+func openTwice(t *testing.T) (*DB, *DB) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "data.db")
+	var dbs [2]*DB
+	for i := range dbs {
+		db, err := openDatabase(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(db.close)
+		if err := db.init(); err != nil {
+			t.Fatal(err)
+		}
+		dbs[i] = db
+	}
+	return dbs[0], dbs[1]
+}
+
+func mustList(t *testing.T, db *DB) int {
+	t.Helper()
+	id, err := db.createList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func mustItem(t *testing.T, db *DB, listID int) int {
+	t.Helper()
+	id, err := db.createItem(listID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func mustLoad(t *testing.T, db *DB) []List {
+	t.Helper()
+	lists, err := db.loadLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lists
+}
+
+func itemIDs(l List) []int {
+	var ids []int
+	for _, item := range l.items {
+		ids = append(ids, item.id)
+	}
+	return ids
+}
+
+func TestConcurrentEdits(t *testing.T) {
+	a, b := openTwice(t)
+	id := mustItem(t, a, mustList(t, a))
+
+	version, err := a.updateItemContent(id, "from a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := b.updateItemContent(id, "from b", 0)
+	if !errors.Is(err, errConflict) {
+		t.Fatalf("stale edit: err = %v, want errConflict", err)
+	}
+	if current != version {
+		t.Errorf("conflict reports version %d, want %d", current, version)
+	}
+	if _, err := b.updateItemContent(id, "from b", -1); err != nil {
+		t.Fatal(err)
+	}
+	item, _, err := a.getItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.content != "from b" || item.version != version+1 {
+		t.Errorf("entry = %q at version %d, want \"from b\" at %d", item.content, item.version, version+1)
+	}
+}
+
+func TestConcurrentToggleAndEdit(t *testing.T) {
+	a, b := openTwice(t)
+	id := mustItem(t, a, mustList(t, a))
+
+	if _, err := a.updateItemContent(id, "edited", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.updateItemDone(id, true, 0); !errors.Is(err, errConflict) {
+		t.Fatalf("stale toggle: err = %v, want errConflict", err)
+	}
+	item, _, err := b.getItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.done {
+		t.Error("a conflicting toggle was written")
+	}
+	if _, err := b.updateItemDone(id, true, item.version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.updateItemContent(id, "stale", 1); !errors.Is(err, errConflict) {
+		t.Errorf("edit after a toggle: err = %v, want errConflict", err)
+	}
+}
+
+func TestConcurrentListRenames(t *testing.T) {
+	a, b := openTwice(t)
+	id := mustList(t, a)
+	if _, err := a.updateListName("Work", id, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.updateListName("Home", id, 0); !errors.Is(err, errConflict) {
+		t.Errorf("stale rename: err = %v, want errConflict", err)
+	}
+}
+
+func TestSaveItemOrderMerge(t *testing.T) {
+	a, b := openTwice(t)
+	listID := mustList(t, a)
+	var ids []int
+	for i := 0; i < 3; i++ {
+		ids = append(ids, mustItem(t, a, listID))
+	}
+	ours := mustLoad(t, a)[0]
+	if _, err := a.saveItemOrder(&ours); err != nil {
+		t.Fatal(err)
+	}
+	theirs := mustLoad(t, b)[0]
+
+	// a adds an entry and moves it to the top, b still has the old order
+	// and moves the last entry to the top.
+	added := mustItem(t, a, listID)
+	ours.items = append([]Item{{id: added}}, ours.items...)
+	if conflict, err := a.saveItemOrder(&ours); err != nil || conflict {
+		t.Fatalf("first save: conflict %v, err %v", conflict, err)
+	}
+	theirs.items = append(theirs.items[2:], theirs.items[:2]...)
+	conflict, err := b.saveItemOrder(&theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conflict {
+		t.Error("stale save reported no conflict")
+	}
+
+	got := fmt.Sprint(itemIDs(mustLoad(t, a)[0]))
+	want := fmt.Sprint([]int{ids[2], ids[0], ids[1], added})
+	if got != want {
+		t.Errorf("merged order = %s, want %s", got, want)
+	}
+}
+
+func TestSaveListOrderMerge(t *testing.T) {
+	a, b := openTwice(t)
+	first, second := mustList(t, a), mustList(t, a)
+	if _, err := a.saveListOrder(mustLoad(t, a)); err != nil {
+		t.Fatal(err)
+	}
+	theirs := mustLoad(t, b)
+
+	third := mustList(t, a)
+	if conflict, err := a.saveListOrder(mustLoad(t, a)); err != nil || conflict {
+		t.Fatalf("first save: conflict %v, err %v", conflict, err)
+	}
+	theirs[0], theirs[1] = theirs[1], theirs[0]
+	conflict, err := b.saveListOrder(theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conflict {
+		t.Error("stale save reported no conflict")
+	}
+
+	var got []int
+	for _, l := range mustLoad(t, a) {
+		got = append(got, l.ID)
+	}
+	if want := []int{second, first, third}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("merged order = %v, want %v", got, want)
+	}
+}
+
+func TestMergeOrder(t *testing.T) {
+	tests := []struct {
+		ours, theirs, want []int
+	}{
+		{[]int{1, 2, 3}, []int{3, 2, 1}, []int{1, 2, 3}},
+		// entries only they know are appended
+		{[]int{2, 1}, []int{1, 2, 3}, []int{2, 1, 3}},
+		// entries they deleted are dropped
+		{[]int{3, 2, 1}, []int{1, 3}, []int{3, 1}},
+		{nil, []int{1}, []int{1}},
+	}
+	for _, tt := range tests {
+		if got := mergeOrder(tt.ours, tt.theirs); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("mergeOrder(%v, %v) = %v, want %v", tt.ours, tt.theirs, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryOutlivesDeletedEntry(t *testing.T) {
+	a, b := openTwice(t)
+	id := mustItem(t, a, mustList(t, a))
+	if _, err := a.updateItemContent(id, "edited", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.deleteItem(id); err != nil {
+		t.Fatal(err)
+	}
+	// Another instance starting must leave the history to a's undo.
+	if err := b.init(); err != nil {
+		t.Fatal(err)
+	}
+	history, err := a.itemHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 || history[len(history)-1].text != "deleted" {
+		t.Errorf("history after another start = %+v, want it to end with the deletion", history)
+	}
+}
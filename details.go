@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// minSplitWidth is the narrowest terminal that shows the details pane, below
+// it the split layout shows the list alone.
+const minSplitWidth = 70
+
+const (
+	minSplitRatio  = 20
+	maxSplitRatio  = 80
+	splitRatioStep = 5
+)
+
+const dateFormat = "2006-01-02"
+const timeFormat = "2006-01-02 15:04"
+
+func (ui *UI) toggleSplit() {
+	if ui.layout == splitLayout {
+		ui.layout = listLayout
+	} else {
+		ui.layout = splitLayout
+	}
+	ui.calculateWindow()
+}
+
+// split reports whether the details pane is shown.
+func (ui *UI) split() bool {
+	return ui.layout == splitLayout && ui.width() >= minSplitWidth
+}
+
+// listWidth is the number of columns the list takes, the details pane gets
+// the rest.
+func (ui *UI) listWidth() int {
+	if !ui.split() {
+		return ui.width()
+	}
+	return ui.width() * ui.splitRatio / 100
+}
+
+func (ui *UI) growList() {
+	ui.splitRatio = min(ui.splitRatio+splitRatioStep, maxSplitRatio)
+	ui.calculateWindow()
+}
+
+func (ui *UI) shrinkList() {
+	ui.splitRatio = max(ui.splitRatio-splitRatioStep, minSplitRatio)
+	ui.calculateWindow()
+}
+
+func renderDetails(ui *UI) {
+	x := ui.listWidth()
+	bottom := ui.height() - footerHeight - bottomOffset
+	for y := topOffset; y < bottom; y++ {
+		ui.screen.SetContent(x, y, '│', nil, darkSecondary)
+	}
+	x += 2
+	width := ui.width() - x - leftOffset
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() || width < 1 {
+		return
+	}
+	item := l.currentItem()
+	y := topOffset
+	line := func(s string, style tcell.Style) {
+		clusters := graphemes(s)
+		for _, r := range wrap(clusters, width) {
+			if y >= bottom {
+				return
+			}
+			drawClipped(ui, x, y, width, strings.Join(clusters[r[0]:r[1]], ""), style)
+			y++
+		}
+	}
+	heading := func(s string) {
+		y++
+		if y < bottom {
+			drawClipped(ui, x, y, width, separator(ui, padChunk(s), ui.width()-width-2), primaryLight)
+			y++
+		}
+	}
+
+	line(item.content, darkLight)
+	heading("Details")
+	state := "open"
+	if item.done {
+		state = "done"
+	}
+	line("Status:   "+state, darkLight)
+	line("Due:      "+orNone(item.due), darkLight)
+	line("Priority: "+orNone(strings.Repeat("!", item.priority)), darkLight)
+	var tags []string
+	for _, tag := range item.tags {
+		tags = append(tags, "#"+tag)
+	}
+	line("Tags:     "+orNone(strings.Join(tags, " ")), darkLight)
+	line("Created:  "+formatTime(item.created), darkLight)
+	if item.done {
+		line("Done:     "+formatTime(item.doneAt), darkLight)
+	}
+
+	heading("Notes")
+	if item.notes == "" {
+		line("Use :note to add notes", darkSecondary)
+	} else {
+		line(item.notes, darkLight)
+	}
+
+	heading("History")
+	history, err := ui.itemHistory(item.id)
+	if err != nil {
+		line(err.Error(), tertiaryLight)
+		return
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		line(history[i].at.Format(timeFormat)+"  "+history[i].text, darkLight)
+	}
+}
+
+// historyCache holds the history of the entry shown in the details pane, so
+// it is not read on every frame.
+type historyCache struct {
+	id      int
+	loaded  bool
+	entries []HistoryEntry
+	err     error
+}
+
+// itemHistory returns the history of the entry id, reading it only when
+// another entry is shown or the entries changed since.
+func (ui *UI) itemHistory(id int) ([]HistoryEntry, error) {
+	c := &ui.history
+	if !c.loaded || c.id != id {
+		c.entries, c.err = ui.db.itemHistory(id)
+		c.id, c.loaded = id, true
+	}
+	return c.entries, c.err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatTime shows the zero time of entries created before timestamps were
+// recorded as unknown.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Format(timeFormat)
+}
+
+// promptNote opens the command line with the notes of the current entry.
+func (ui *UI) promptNote() {
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() {
+		return
+	}
+	ui.promptCommand(strings.TrimSpace("note " + l.currentItem().notes))
+}
+
+func (ui *UI) cmdNote(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if !l.hasCurrent() {
+		return errNoEntry
+	}
+	ids := ui.targetIDs()
+	return ui.change("note", ids, []*List{l}, func(step *undoStep) error {
+		sealed, err := ui.db.seal(arg)
+		if err != nil {
+			return err
+		}
+		return ui.db.updateItems(ids, "notes", sealed)
+	})
+}
+
+func (ui *UI) cmdDue(arg string) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if !l.hasCurrent() {
+		return errNoEntry
+	}
+	due, err := parseDue(arg, time.Now())
+	if err != nil {
+		return err
+	}
+	return ui.setDue(ui.targetIDs(), due)
+}
+
+// setDue sets the due date of the entries ids as one undoable step.
+func (ui *UI) setDue(ids []int, due string) error {
+	return ui.change("due date", ids, nil, func(step *undoStep) error {
+		return ui.db.updateItems(ids, "due", due)
+	})
+}
+
+// parseDue reads a due date as YYYY-MM-DD, today, tomorrow, +<n>d, +<n>w or
+// a weekday name and returns it as YYYY-MM-DD. none removes the due date.
+func parseDue(arg string, now time.Time) (string, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	arg = strings.ToLower(strings.TrimSpace(arg))
+	switch arg {
+	case "none", "-":
+		return "", nil
+	case "today":
+		return today.Format(dateFormat), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1).Format(dateFormat), nil
+	}
+	if strings.HasPrefix(arg, "+") && len(arg) > 2 {
+		n, err := strconv.Atoi(arg[1 : len(arg)-1])
+		if err == nil {
+			switch arg[len(arg)-1] {
+			case 'd':
+				return today.AddDate(0, 0, n).Format(dateFormat), nil
+			case 'w':
+				return today.AddDate(0, 0, 7*n).Format(dateFormat), nil
+			}
+		}
+	}
+	for d := 1; d <= 7 && len(arg) >= 3; d++ {
+		day := today.AddDate(0, 0, d)
+		if strings.HasPrefix(strings.ToLower(day.Weekday().String()), arg) {
+			return day.Format(dateFormat), nil
+		}
+	}
+	if t, err := time.ParseInLocation(dateFormat, arg, now.Location()); err == nil {
+		return t.Format(dateFormat), nil
+	}
+	return "", errors.New("usage: due <YYYY-MM-DD|today|tomorrow|+<n>d|+<n>w|<weekday>|none>")
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DoneView is how a list shows its done entries.
+type DoneView int
+
+const (
+	doneShown DoneView = iota
+	doneHidden
+	// doneBottom gathers the done entries in a section below the open
+	// ones, doneCollapsed shows only the heading of that section
+	doneBottom
+	doneCollapsed
+)
+
+var doneViewNames = map[string]DoneView{
+	"show":     doneShown,
+	"hide":     doneHidden,
+	"bottom":   doneBottom,
+	"collapse": doneCollapsed,
+}
+
+var doneViewTexts = map[DoneView]string{
+	doneShown:     "Done entries are shown",
+	doneHidden:    "Done entries are hidden",
+	doneBottom:    "Done entries are shown at the bottom",
+	doneCollapsed: "Done entries are collapsed",
+}
+
+// visible returns the indexes of the entries that are shown, in the order
+// they are shown.
+func (l *List) visible() []int {
+	var open, done []int
+	for i, item := range l.items {
+		switch {
+		case !item.done || l.doneView == doneShown:
+			open = append(open, i)
+		case l.doneView == doneBottom:
+			done = append(done, i)
+		}
+	}
+	return append(open, done...)
+}
+
+// position returns where entry i is shown, -1 if it is hidden.
+func (l *List) position(i int) int {
+	for p, j := range l.visible() {
+		if j == i {
+			return p
+		}
+	}
+	return -1
+}
+
+// hasCurrent reports whether the cursor is on a shown entry. When all
+// entries are hidden there is none.
+func (l *List) hasCurrent() bool {
+	return l.position(l.row) >= 0
+}
+
+// completedAt returns the position the "Completed" heading is shown above,
+// -1 if there is none.
+func (l *List) completedAt() int {
+	if l.doneView != doneBottom && l.doneView != doneCollapsed || l.doneCount() == 0 {
+		return -1
+	}
+	return len(l.items) - l.doneCount()
+}
+
+func (l *List) doneCount() int {
+	var n int
+	for _, item := range l.items {
+		if item.done {
+			n++
+		}
+	}
+	return n
+}
+
+// fixRow moves the cursor off a hidden entry to the next shown one, or the
+// last one if there is none below.
+func (l *List) fixRow() {
+	if len(l.items) == 0 || l.hasCurrent() {
+		return
+	}
+	visible := l.visible()
+	for _, i := range visible {
+		if i > l.row {
+			l.row = i
+			return
+		}
+	}
+	if len(visible) != 0 {
+		l.row = visible[len(visible)-1]
+	}
+}
+
+func (ui *UI) setDoneView(v DoneView) error {
+	l := ui.currentList()
+	if l == nil {
+		return errNoList
+	}
+	if l.smart() {
+		return errSmartList
+	}
+	if err := ui.db.updateListDoneView(l.ID, v); err != nil {
+		return err
+	}
+	l.doneView = v
+	l.fixRow()
+	ui.calculateWindow()
+	ui.notify(doneViewTexts[v])
+	return nil
+}
+
+// toggleHideDone hides the done entries, or shows them between the open ones
+// if they are hidden.
+func (ui *UI) toggleHideDone() {
+	l := ui.currentList()
+	if l == nil {
+		return
+	}
+	v := doneHidden
+	if l.doneView == doneHidden {
+		v = doneShown
+	}
+	if err := ui.setDoneView(v); err != nil {
+		ui.fail(err)
+	}
+}
+
+// toggleCollapseDone gathers the done entries in a collapsed section, or
+// expands and collapses the section.
+func (ui *UI) toggleCollapseDone() {
+	l := ui.currentList()
+	if l == nil {
+		return
+	}
+	v := doneCollapsed
+	if l.doneView == doneCollapsed {
+		v = doneBottom
+	}
+	if err := ui.setDoneView(v); err != nil {
+		ui.fail(err)
+	}
+}
+
+func (ui *UI) cmdDone(arg string) error {
+	v, ok := doneViewNames[arg]
+	if !ok {
+		return errors.New("usage: done show|hide|bottom|collapse")
+	}
+	return ui.setDoneView(v)
+}
+
+// completedHeading is the heading above the done entries gathered at the
+// bottom.
+func (l *List) completedHeading() string {
+	arrow := "▾"
+	if l.doneView == doneCollapsed {
+		arrow = "▸"
+	}
+	return fmt.Sprintf("%s Completed (%d)", arrow, l.doneCount())
+}
+
+func renderCompleted(ui *UI, l *List, y int) {
+	drawText(ui, leftOffset, y, l.completedHeading(), darkSecondary)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
+)
+
+// lineEditor edits a single line of text in grapheme clusters, so a cursor
+// step or a deletion never splits a character made of several runes. It is
+// shared by the entry and the list name editor.
+type lineEditor struct {
+	clusters []string
+	cursor   int
+	// scroll is the first cluster shown if the line is wider than the
+	// space it is rendered in
+	scroll int
+	// original is the text before editing, restored on cancel
+	original string
+	// fresh marks a new entry or list, cancelling removes it
+	fresh bool
+}
+
+// newLineEditor starts editing s with the cursor at the end. A single space
+// is the placeholder of an empty entry or name and starts an empty line.
+func newLineEditor(s string) lineEditor {
+	e := lineEditor{original: s}
+	if s == " " {
+		s = ""
+	}
+	e.clusters = graphemes(s)
+	e.cursor = len(e.clusters)
+	return e
+}
+
+func (e *lineEditor) String() string {
+	return strings.Join(e.clusters, "")
+}
+
+// value is the text to store, the placeholder if the line is empty.
+func (e *lineEditor) value() string {
+	if len(e.clusters) == 0 {
+		return " "
+	}
+	return e.String()
+}
+
+// insert adds r at the cursor. The text is segmented again since r may
+// combine with the cluster before it, like an accent or a skin tone.
+func (e *lineEditor) insert(r rune) {
+	if r == ' ' && e.cursor == 0 {
+		return
+	}
+	e.insertText(string(r))
+}
+
+func (e *lineEditor) insertText(s string) {
+	before := strings.Join(e.clusters[:e.cursor], "") + s
+	after := strings.Join(e.clusters[e.cursor:], "")
+	e.clusters = graphemes(before + after)
+	e.cursor = min(len(graphemes(before)), len(e.clusters))
+}
+
+// cut removes the clusters from i to j and returns them.
+func (e *lineEditor) cut(i, j int) string {
+	text := strings.Join(e.clusters[i:j], "")
+	e.clusters = append(e.clusters[:i], e.clusters[j:]...)
+	e.cursor = i
+	return text
+}
+
+func (e *lineEditor) deleteBack() {
+	if e.cursor > 0 {
+		e.cut(e.cursor-1, e.cursor)
+	}
+}
+
+func (e *lineEditor) deleteForward() {
+	if e.cursor < len(e.clusters) {
+		e.cut(e.cursor, e.cursor+1)
+	}
+}
+
+// killWordBack cuts the word before the cursor including the whitespace
+// after it, like ctrl-w in a shell.
+func (e *lineEditor) killWordBack() string {
+	i := e.cursor
+	for i > 0 && isSpace(e.clusters[i-1]) {
+		i--
+	}
+	for i > 0 && !isSpace(e.clusters[i-1]) {
+		i--
+	}
+	return e.cut(i, e.cursor)
+}
+
+func (e *lineEditor) killToStart() string {
+	return e.cut(0, e.cursor)
+}
+
+func (e *lineEditor) killToEnd() string {
+	cursor := e.cursor
+	text := e.cut(e.cursor, len(e.clusters))
+	e.cursor = cursor
+	return text
+}
+
+func (e *lineEditor) left() {
+	if e.cursor > 0 {
+		e.cursor--
+	}
+}
+
+func (e *lineEditor) right() {
+	if e.cursor < len(e.clusters) {
+		e.cursor++
+	}
+}
+
+func (e *lineEditor) home() {
+	e.cursor = 0
+}
+
+func (e *lineEditor) end() {
+	e.cursor = len(e.clusters)
+}
+
+// wordLeft moves the cursor to the start of the word before it.
+func (e *lineEditor) wordLeft() {
+	for e.cursor > 0 && !isWord(e.clusters[e.cursor-1]) {
+		e.cursor--
+	}
+	for e.cursor > 0 && isWord(e.clusters[e.cursor-1]) {
+		e.cursor--
+	}
+}
+
+// wordRight moves the cursor to the end of the word after it.
+func (e *lineEditor) wordRight() {
+	for e.cursor < len(e.clusters) && !isWord(e.clusters[e.cursor]) {
+		e.cursor++
+	}
+	for e.cursor < len(e.clusters) && isWord(e.clusters[e.cursor]) {
+		e.cursor++
+	}
+}
+
+func isWord(c string) bool {
+	for _, r := range c {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	return false
+}
+
+func isSpace(c string) bool {
+	for _, r := range c {
+		return unicode.IsSpace(r)
+	}
+	return false
+}
+
+// maxKills is the number of cut texts kept in the kill ring.
+const maxKills = 10
+
+func (ui *UI) addKill(text string) {
+	if text == "" {
+		return
+	}
+	ui.killRing = append(ui.killRing, text)
+	if len(ui.killRing) > maxKills {
+		ui.killRing = ui.killRing[1:]
+	}
+}
+
+// yankKill inserts the last cut text.
+func (ui *UI) yankKill() {
+	if len(ui.killRing) == 0 {
+		return
+	}
+	ui.killIndex = len(ui.killRing) - 1
+	ui.yanked = ui.killRing[ui.killIndex]
+	ui.editor.insertText(ui.yanked)
+	ui.syncEdit()
+}
+
+// yankPop replaces the text inserted by the last yank with the one cut
+// before it, going around the ring.
+func (ui *UI) yankPop() {
+	if ui.yanked == "" {
+		return
+	}
+	n := len(graphemes(ui.yanked))
+	ui.editor.cut(max(ui.editor.cursor-n, 0), ui.editor.cursor)
+	ui.killIndex = (ui.killIndex + len(ui.killRing) - 1) % len(ui.killRing)
+	ui.yanked = ui.killRing[ui.killIndex]
+	ui.editor.insertText(ui.yanked)
+	ui.syncEdit()
+}
+
+// render draws the line at x, y into width cells with the cursor cell in
+// secondaryLight, scrolling horizontally to keep the cursor visible.
+func (e *lineEditor) render(ui *UI, x, y, width int, style tcell.Style) {
+	e.scroll = min(e.scroll, e.cursor)
+	for e.scroll < e.cursor && textWidth(strings.Join(e.clusters[e.scroll:e.cursor], ""))+1 > width {
+		e.scroll++
+	}
+	end := x + width
+	for i := e.scroll; i < len(e.clusters); i++ {
+		s := style
+		if i == e.cursor {
+			s = secondaryLight
+		}
+		if x+clusterWidth(e.clusters[i]) > end {
+			return
+		}
+		x += drawCluster(ui, x, y, e.clusters[i], s)
+	}
+	if e.cursor == len(e.clusters) {
+		ui.screen.SetContent(x, y, ' ', nil, secondaryLight)
+	}
+}
+
+// wrap breaks the clusters into lines of at most width cells, after the
+// last space that fits or, for a longer word, right at the edge. It returns
+// the start and end index of every line.
+func wrap(clusters []string, width int) [][2]int {
+	var lines [][2]int
+	// atSpace is the width of the line up to and including the last space,
+	// w-atSpace that of the word after it.
+	start, space, w, atSpace := 0, -1, 0, 0
+	for i := 0; i < len(clusters); i++ {
+		cw := clusterWidth(clusters[i])
+		switch {
+		case w+cw <= width || i == start:
+			w += cw
+		case isSpace(clusters[i]):
+			// The space the line breaks at is dropped.
+			lines = append(lines, [2]int{start, i})
+			start, space, w = i+1, -1, 0
+			continue
+		case space >= start && w-atSpace+cw <= width:
+			lines = append(lines, [2]int{start, space + 1})
+			start, space, w = space+1, -1, w-atSpace+cw
+		default:
+			lines = append(lines, [2]int{start, i})
+			start, space, w = i, -1, cw
+		}
+		if isSpace(clusters[i]) {
+			space, atSpace = i, w
+		}
+	}
+	return append(lines, [2]int{start, len(clusters)})
+}
+
+func graphemes(s string) []string {
+	var clusters []string
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		clusters = append(clusters, g.Str())
+	}
+	return clusters
+}
+
+// clusterWidth is the number of cells a cluster takes. It goes by the first
+// rune like tcell does when drawing it.
+func clusterWidth(c string) int {
+	for _, r := range c {
+		return max(runewidth.RuneWidth(r), 1)
+	}
+	return 0
+}
+
+func textWidth(s string) int {
+	var w int
+	for _, c := range graphemes(s) {
+		w += clusterWidth(c)
+	}
+	return w
+}
+
+func drawCluster(ui *UI, x, y int, c string, style tcell.Style) int {
+	runes := []rune(c)
+	ui.screen.SetContent(x, y, runes[0], runes[1:], style)
+	return clusterWidth(c)
+}
+
+// drawText draws s from x on and returns its width in cells.
+func drawText(ui *UI, x, y int, s string, style tcell.Style) int {
+	start := x
+	for _, c := range graphemes(s) {
+		x += drawCluster(ui, x, y, c, style)
+	}
+	return x - start
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	acute  = "e\u0301"                                    // e with a combining acute accent
+	family = "\U0001F468\u200D\U0001F469\u200D\U0001F467" // joined by zero width joiners
+	thumb  = "\U0001F44D\U0001F3FD"                       // with a skin tone modifier
+	pair   = "\U0001F1E9\U0001F1EA"                       // a flag of regional indicators
+	cjk    = "漢字"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"ab", []string{"a", "b"}},
+		{acute + "x", []string{acute, "x"}},
+		{"a" + family + "b", []string{"a", family, "b"}},
+		{thumb + pair, []string{thumb, pair}},
+		{cjk, []string{"漢", "字"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := graphemes(tt.s); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("graphemes(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestClusterWidth(t *testing.T) {
+	tests := []struct {
+		c    string
+		want int
+	}{
+		{"a", 1},
+		{acute, 1},
+		{"漢", 2},
+		{"👍", 2},
+		{thumb, 2},
+		{family, 2},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := clusterWidth(tt.c); got != tt.want {
+			t.Errorf("clusterWidth(%q) = %d, want %d", tt.c, got, tt.want)
+		}
+	}
+	if got := textWidth("a" + cjk + acute); got != 6 {
+		t.Errorf("textWidth = %d, want 6", got)
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		edit   func(e *lineEditor)
+		want   string
+		cursor int
+	}{
+		{"delete combined accent", "a" + acute, (*lineEditor).deleteBack, "a", 1},
+		{"delete zwj sequence", "a" + family, (*lineEditor).deleteBack, "a", 1},
+		{"delete skin tone emoji", thumb + "b", func(e *lineEditor) {
+			e.home()
+			e.deleteForward()
+		}, "b", 0},
+		{"step over wide clusters", cjk + pair, func(e *lineEditor) {
+			e.left()
+			e.left()
+		}, cjk + pair, 1},
+		{"accent combines with the cluster before", "cafe", func(e *lineEditor) {
+			e.insert('\u0301')
+		}, "caf" + acute, 4},
+		{"skin tone combines with the emoji", "\U0001F44D", func(e *lineEditor) {
+			e.insert('\U0001F3FD')
+		}, thumb, 1},
+		{"insert in the middle", "ac", func(e *lineEditor) {
+			e.left()
+			e.insert('b')
+		}, "abc", 2},
+		{"no leading space", "", func(e *lineEditor) {
+			e.insert(' ')
+		}, "", 0},
+		{"word left", "foo bar_baz " + cjk, func(e *lineEditor) {
+			e.wordLeft()
+			e.wordLeft()
+		}, "foo bar_baz " + cjk, 4},
+		{"word right", "foo, " + acute + "t" + acute, func(e *lineEditor) {
+			e.home()
+			e.wordRight()
+			e.wordRight()
+		}, "foo, " + acute + "t" + acute, 8},
+		{"placeholder is an empty line", " ", func(e *lineEditor) {}, "", 0},
+	}
+	for _, tt := range tests {
+		e := newLineEditor(tt.text)
+		tt.edit(&e)
+		if e.String() != tt.want || e.cursor != tt.cursor {
+			t.Errorf("%s: %q with cursor %d, want %q with cursor %d", tt.name, e.String(), e.cursor, tt.want, tt.cursor)
+		}
+	}
+}
+
+func TestLineEditorKills(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		cursor int
+		kill   func(e *lineEditor) string
+		killed string
+		want   string
+	}{
+		{"word with the space after it", "foo bar  ", 9, (*lineEditor).killWordBack, "bar  ", "foo "},
+		{"word of wide clusters", "a " + cjk, 4, (*lineEditor).killWordBack, cjk, "a "},
+		{"to the start", "a" + family + "b", 2, (*lineEditor).killToStart, "a" + family, "b"},
+		{"to the end", acute + "xy", 1, (*lineEditor).killToEnd, "xy", acute},
+		{"nothing before the cursor", "abc", 0, (*lineEditor).killWordBack, "", "abc"},
+	}
+	for _, tt := range tests {
+		e := newLineEditor(tt.text)
+		e.cursor = tt.cursor
+		if killed := tt.kill(&e); killed != tt.killed || e.String() != tt.want {
+			t.Errorf("%s: killed %q leaving %q, want %q leaving %q", tt.name, killed, e.String(), tt.killed, tt.want)
+		}
+	}
+}
+
+func TestKillRing(t *testing.T) {
+	ui := &UI{}
+	ui.editor = newLineEditor("")
+	for _, text := range []string{"one ", "", "two ", "three "} {
+		ui.addKill(text)
+	}
+	if len(ui.killRing) != 3 {
+		t.Fatalf("kill ring = %q, empty kills are not kept", ui.killRing)
+	}
+
+	ui.yankKill()
+	want := []string{"three ", "two ", "one ", "three "}
+	for i, w := range want {
+		if i > 0 {
+			ui.yankPop()
+		}
+		if got := ui.editor.String(); got != w {
+			t.Errorf("after %d yank pops: %q, want %q", i, got, w)
+		}
+	}
+
+	// A yank pop replaces only the yanked text, also one of several
+	// clusters.
+	ui.editor = newLineEditor("x")
+	ui.addKill(family + acute)
+	ui.yankKill()
+	ui.yankPop()
+	if got := ui.editor.String(); got != "xthree " {
+		t.Errorf("yank pop after a multi-rune yank: %q, want %q", got, "xthree ")
+	}
+
+	for i := 0; i < maxKills+5; i++ {
+		ui.addKill(fmt.Sprint(i))
+	}
+	if len(ui.killRing) != maxKills || ui.killRing[0] != "5" {
+		t.Errorf("kill ring holds %d texts starting with %q, want the last %d", len(ui.killRing), ui.killRing[0], maxKills)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"buy milk and eggs", 8, []string{"buy milk", "and eggs"}},
+		{"buy milk", 20, []string{"buy milk"}},
+		{"supercalifragilistic", 8, []string{"supercal", "ifragili", "stic"}},
+		{"ab 漢字漢字", 6, []string{"ab ", "漢字漢", "字"}},
+		{acute + acute + " " + acute, 2, []string{acute + acute, acute}},
+		{"a " + family + family, 4, []string{"a ", family + family}},
+		// A wide cluster that does not fit after a break at the space
+		// is put on the next line.
+		{" 漢👍a", 3, []string{" 漢", "👍a"}},
+		{"漢", 1, []string{"漢"}},
+	}
+	for _, tt := range tests {
+		clusters := graphemes(tt.text)
+		var got []string
+		for _, line := range wrap(clusters, tt.width) {
+			got = append(got, strings.Join(clusters[line[0]:line[1]], ""))
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}
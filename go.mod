@@ -3,14 +3,17 @@ module github.com/kilianmandscharo/todo
 go 1.19
 
 require (
-	github.com/gdamore/tcell v1.4.0
+	github.com/gdamore/tcell/v2 v2.7.4
+	github.com/mattn/go-runewidth v0.0.15
 	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/rivo/uniseg v0.4.4
+	golang.org/x/crypto v0.14.0
+	golang.org/x/term v0.17.0
 )
 
 require (
 	github.com/gdamore/encoding v1.0.0 // indirect
-	github.com/lucasb-eyer/go-colorful v1.0.3 // indirect
-	github.com/mattn/go-runewidth v0.0.7 // indirect
-	golang.org/x/sys v0.0.0-20190626150813-e07cf5db2756 // indirect
-	golang.org/x/text v0.3.0 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 )
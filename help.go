@@ -0,0 +1,93 @@
+package main
+
+type helpOverlay struct {
+	// mode is the mode the help was opened from, its keys are shown and
+	// it is restored on close
+	mode Mode
+	top  int
+}
+
+func (ui *UI) openHelp() {
+	ui.help = helpOverlay{mode: ui.mode}
+	ui.mode = helpMode
+}
+
+func (ui *UI) closeHelp() {
+	ui.mode = ui.help.mode
+}
+
+func (ui *UI) helpDown() {
+	if ui.help.top+ui.helpHeight() < len(helpLines(ui.help.mode)) {
+		ui.help.top++
+	}
+}
+
+func (ui *UI) helpUp() {
+	if ui.help.top > 0 {
+		ui.help.top--
+	}
+}
+
+func helpLines(mode Mode) []string {
+	section, ok := sectionByMode(mode)
+	if !ok {
+		return nil
+	}
+	return section.lines()
+}
+
+// helpHeight is the number of lines that fit into the overlay.
+func (ui *UI) helpHeight() int {
+	return max(ui.height()-footerHeight-topOffset-4, 0)
+}
+
+func renderHelp(ui *UI) {
+	section, ok := sectionByMode(ui.help.mode)
+	if !ok {
+		return
+	}
+	lines := section.lines()
+	width := 0
+	for _, line := range lines {
+		width = max(width, len([]rune(line)))
+	}
+	width = min(width+4, ui.width()-2*leftOffset)
+	height := min(len(lines), ui.helpHeight()) + 4
+	left := (ui.width() - width) / 2
+	top := topOffset
+
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			ui.screen.SetContent(left+col, top+row, ' ', nil, darkLight)
+		}
+	}
+	title := padChunk("Keys: " + section.title)
+	for col := 0; col < width; col++ {
+		ui.screen.SetContent(left+col, top, ' ', nil, primaryLight)
+		ui.screen.SetContent(left+col, top+height-1, ' ', nil, primaryLight)
+	}
+	for col, r := range []rune(title) {
+		if col < width {
+			ui.screen.SetContent(left+col, top, r, nil, primaryLight)
+		}
+	}
+	if len(lines) > height-4 {
+		scroll := padChunk(actionKey(helpMode, "down") + " / " + actionKey(helpMode, "up") + " scroll")
+		for col, r := range []rune(scroll) {
+			if col < width {
+				ui.screen.SetContent(left+col, top+height-1, r, nil, primaryLight)
+			}
+		}
+	}
+
+	for row, line := range lines[min(ui.help.top, len(lines)):] {
+		if row >= height-4 {
+			break
+		}
+		for col, r := range []rune(line) {
+			if col < width-4 {
+				ui.screen.SetContent(left+2+col, top+2+row, r, nil, darkLight)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type HistoryEntry struct {
+	at   time.Time
+	text string
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// logHistory records text in the history of the entry id.
+func (db *DB) logHistory(e execer, id int, text string) error {
+	sealed, err := db.seal(text)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec("INSERT INTO history (item_id, at, text) VALUES (?, ?, ?)", id, now(), sealed)
+	return err
+}
+
+// itemHistory returns the history of the entry id, oldest first.
+func (db *DB) itemHistory(id int) ([]HistoryEntry, error) {
+	rows, err := db.db.Query("SELECT at, text FROM history WHERE item_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []HistoryEntry
+	for rows.Next() {
+		var at int64
+		var text string
+		if err := rows.Scan(&at, &text); err != nil {
+			return nil, err
+		}
+		if text, err = db.unseal(text); err != nil {
+			return nil, err
+		}
+		history = append(history, HistoryEntry{unixTime(at), text})
+	}
+	return history, rows.Err()
+}
+
+// historyText describes setting column to value for updateItems.
+func (db *DB) historyText(tx *sql.Tx, column string, value any) (string, error) {
+	switch column {
+	case "done":
+		return doneText(value.(bool)), nil
+	case "list_id":
+		var name string
+		if err := tx.QueryRow("SELECT name FROM list WHERE id = ?", value).Scan(&name); err != nil {
+			return "", err
+		}
+		name, err := db.unseal(name)
+		if err != nil {
+			return "", err
+		}
+		return "moved to " + name, nil
+	case "priority":
+		return fmt.Sprintf("priority set to %d", value), nil
+	case "notes":
+		return "notes changed", nil
+	case "due":
+		if value == "" {
+			return "due date removed", nil
+		}
+		return fmt.Sprintf("due date set to %s", value), nil
+	}
+	return column + " changed", nil
+}
+
+func doneText(done bool) string {
+	if done {
+		return "marked done"
+	}
+	return "reopened"
+}
+
+func tagsText(tags []string) string {
+	if len(tags) == 0 {
+		return "tags removed"
+	}
+	return "tags set to #" + strings.Join(tags, " #")
+}
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/gdamore/tcell"
+	"github.com/gdamore/tcell/v2"
 )
 
 type List struct {
-	ID    int
-	name  string
-	row   int
-	col   int
-	items []Item
+	ID           int
+	name         string
+	row          int
+	top          int // first entry shown in the column of the board
+	items        []Item
+	version      int
+	orderVersion int
+	doneView     DoneView
+	sortOrder    sortOrder
+	query        string // the query of a smart list
 }
 
 type Item struct {
-	id      int
-	content string
-	done    bool
+	id       int
+	content  string
+	done     bool
+	version  int
+	tags     []string
+	priority int
+	notes    string
+	due      string // YYYY-MM-DD or empty
+	created  time.Time
+	doneAt   time.Time
+	owner    string // the name of its list, in a smart list
 }
 
 func (l *List) render(ui *UI) {
@@ -26,14 +42,10 @@ func (l *List) render(ui *UI) {
 }
 
 func renderHeader(ui *UI, l *List) {
-	for col, r := range []rune(l.name + " ") {
-		var style tcell.Style
-		if ui.mode == editListNameMode && col == l.col {
-			style = secondaryLight
-		} else {
-			style = darkLight
-		}
-		ui.screen.SetContent(leftOffset+col, 3, r, nil, style)
+	if ui.mode == editListNameMode {
+		ui.editor.render(ui, leftOffset, 3, ui.listWidth()-2*leftOffset, darkLight)
+	} else {
+		drawText(ui, leftOffset, 3, l.name, darkLight)
 	}
 
 	total := len(l.items)
@@ -49,16 +61,43 @@ func renderHeader(ui *UI, l *List) {
 		}
 		topLine = padChunk(fmt.Sprintf("%d / %d done", done, total))
 	}
-	renderTopSeparator(ui, separator(ui, topLine, 0), 5)
+	if l.sortOrder.key != "" {
+		topLine += padChunk("sorted by " + l.sortOrder.String())
+	}
+	if l.smart() {
+		topLine += padChunk("smart list: " + l.query)
+	}
+	renderTopSeparator(ui, separator(ui, topLine, ui.width()-ui.listWidth()), 5)
 }
 
 func renderBody(ui *UI, l *List) {
-	if len(l.items) == 0 {
-		ui.renderLine("Press n to create an entry", headerHeight)
+	if len(l.items) == 0 && l.smart() {
+		ui.renderLine("No entries match the query", headerHeight)
+	} else if len(l.items) == 0 {
+		ui.renderLine("Press "+actionKey(normalMode, "new-entry")+" to create an entry", headerHeight)
+	} else if l.doneView == doneHidden && !l.hasCurrent() {
+		ui.renderLine(fmt.Sprintf("All entries are done, press %s to show them", actionKey(normalMode, "hide-done")), headerHeight)
 	}
-	for row, item := range l.items[ui.windowTop:ui.windowBottom] {
-		rowWithOffset := row + topOffset + headerHeight
-		rowWithW := row + ui.windowTop
+	rowWithOffset := topOffset + headerHeight
+	bottom := rowWithOffset + ui.listSpaceAvailable()
+	visible := l.visible()
+	selected := ui.selected(l)
+	completed := l.completedAt()
+	defer func() {
+		if completed == len(visible) && ui.windowBottom == len(visible) && rowWithOffset < bottom {
+			renderCompleted(ui, l, rowWithOffset)
+		}
+	}()
+	for p := ui.windowTop; p < ui.windowBottom; p++ {
+		if p == completed {
+			if rowWithOffset >= bottom {
+				return
+			}
+			renderCompleted(ui, l, rowWithOffset)
+			rowWithOffset++
+		}
+		rowWithW := visible[p]
+		item := l.items[rowWithW]
 		var style tcell.Style
 		if ui.mode != editListNameMode && rowWithW == l.row {
 			if ui.mode == editMode {
@@ -66,6 +105,8 @@ func renderBody(ui *UI, l *List) {
 			} else {
 				style = primaryLight
 			}
+		} else if selected[rowWithW] {
+			style = secondaryDark
 		} else {
 			style = darkLight
 		}
@@ -78,92 +119,137 @@ func renderBody(ui *UI, l *List) {
 		ui.screen.SetContent(0+leftOffset, rowWithOffset, '[', nil, darkLight)
 		ui.screen.SetContent(1+leftOffset, rowWithOffset, marker, nil, darkLight)
 		ui.screen.SetContent(2+leftOffset, rowWithOffset, ']', nil, darkLight)
-		var content string
 		if rowWithW == l.row && ui.mode == editMode {
-			content = item.content + " "
-		} else {
-			content = item.content
+			ui.editor.render(ui, leftOffset+4, rowWithOffset, ui.entryWidth(), style)
+			rowWithOffset++
+			continue
 		}
-		for col, r := range []rune(content) {
-			colWithOffset := col + leftOffset + 4
-			if col == l.col && rowWithW == l.row && ui.mode == editMode {
-				ui.screen.SetContent(colWithOffset, rowWithOffset, r, nil, secondaryLight)
-			} else {
-				ui.screen.SetContent(colWithOffset, rowWithOffset, r, nil, style)
+		clusters, n := item.clusters()
+		for _, line := range wrap(clusters, ui.entryWidth()) {
+			if rowWithOffset >= bottom {
+				return
+			}
+			x := leftOffset + 4
+			for i := line[0]; i < line[1]; i++ {
+				if i < n {
+					x += drawCluster(ui, x, rowWithOffset, clusters[i], style)
+				} else {
+					x += drawCluster(ui, x, rowWithOffset, clusters[i], darkSecondary)
+				}
 			}
+			rowWithOffset++
 		}
 	}
 }
 
+// lines wraps the content and extras of the entry to width, see clusters.
+func (item Item) lines(width int) [][2]int {
+	clusters, _ := item.clusters()
+	return wrap(clusters, width)
+}
+
+// clusters returns the clusters of the content followed by those of the
+// extras and the number of content clusters.
+func (item Item) clusters() ([]string, int) {
+	content := graphemes(item.content)
+	return append(content, graphemes(item.extras())...), len(content)
+}
+
+// extras is the priority and the tags shown after the content of an entry.
+func (item Item) extras() string {
+	var extras string
+	if item.priority > 0 {
+		extras += " " + strings.Repeat("!", item.priority)
+	}
+	for _, tag := range item.tags {
+		extras += " #" + tag
+	}
+	if item.owner != "" {
+		extras += "  " + item.owner
+	}
+	return extras
+}
+
 func (l *List) down(ui *UI) {
-	if l.row+1 <= len(l.items)-1 {
-		if l.row+1 == ui.windowBottom {
-			ui.windowBottom++
-			ui.windowTop++
-		}
-		l.row++
+	if next, ok := l.neighbour(1); ok {
+		l.row = next
+		ui.calculateWindow()
 	}
 }
 
 func (l *List) up(ui *UI) {
-	if l.row-1 >= 0 {
-		if l.row == ui.windowTop {
-			ui.windowTop--
-			ui.windowBottom--
-		}
-		l.row--
+	if previous, ok := l.neighbour(-1); ok {
+		l.row = previous
+		ui.calculateWindow()
 	}
 }
 
-func (l *List) switchUp(ui *UI) {
-	i := l.row
-	if i-1 >= 0 {
-		if l.row == ui.windowTop {
-			ui.windowTop--
-			ui.windowBottom--
-		}
-		l.items[i], l.items[i-1] = l.items[i-1], l.items[i]
-		l.row--
+// neighbour returns the entry shown d positions away from the current one.
+func (l *List) neighbour(d int) (int, bool) {
+	visible := l.visible()
+	p := l.position(l.row)
+	if p < 0 || p+d < 0 || p+d >= len(visible) {
+		return 0, false
 	}
+	return visible[p+d], true
+}
+
+func (l *List) switchUp(ui *UI) {
+	l.switchWith(ui, -1)
 }
 
 func (l *List) switchDown(ui *UI) {
-	i := l.row
-	if i+1 <= len(l.items)-1 {
-		if l.row+1 == ui.windowBottom {
-			ui.windowBottom++
-			ui.windowTop++
-		}
-		l.items[i], l.items[i+1] = l.items[i+1], l.items[i]
-		l.row++
+	l.switchWith(ui, 1)
+}
+
+// switchWith swaps the current entry with the one shown d positions away.
+// Gathered at the bottom, done entries only swap with each other.
+func (l *List) switchWith(ui *UI, d int) {
+	j, ok := l.neighbour(d)
+	if !ok || l.completedAt() >= 0 && l.items[j].done != l.currentItem().done {
+		return
 	}
+	l.items[l.row], l.items[j] = l.items[j], l.items[l.row]
+	l.row = j
+	ui.calculateWindow()
 }
 
-func (l *List) updateName(db *DB) error {
-	if err := db.updateListName(l.name, l.ID); err != nil {
-		return err
+// updateName stores the name, overwriting a concurrent change made by
+// another process. The returned bool reports whether that happened.
+func (l *List) updateName(db *DB) (bool, error) {
+	if l.smart() {
+		return false, db.updateSmartList(-l.ID, "name", l.name)
 	}
-	return nil
+	version, err := db.updateListName(l.name, l.ID, l.version)
+	conflict := errors.Is(err, errConflict)
+	if conflict {
+		version, err = db.updateListName(l.name, l.ID, -1)
+	}
+	if err != nil {
+		return conflict, err
+	}
+	l.version = version
+	return conflict, nil
 }
 
 func (l *List) delete(db *DB, ui *UI) {
-	if len(l.items) == 0 {
+	if !l.hasCurrent() {
 		return
 	}
 	err := db.deleteItem(l.currentItem().id)
 	if err != nil {
 		return
 	}
-	nitems := len(l.items)
-	space := ui.listSpaceAvailable()
-	if nitems-1 < space {
-		ui.windowBottom--
-	} else if ui.windowTop > 0 {
-		ui.windowBottom--
-		ui.windowTop--
-	}
+	l.removeCurrent(ui)
+}
+
+// removeCurrent takes the current entry out of the list without touching the
+// database.
+func (l *List) removeCurrent(ui *UI) {
 	if len(l.items) == 1 {
 		l.items = nil
+		l.row = 0
+		ui.calculateWindow()
 		return
 	}
 	i := l.row
@@ -173,6 +259,8 @@ func (l *List) delete(db *DB, ui *UI) {
 	newItems := l.items[:i]
 	newItems = append(newItems, l.items[i+1:]...)
 	l.items = newItems
+	l.fixRow()
+	ui.calculateWindow()
 }
 
 func (l *List) add(db *DB, ui *UI) {
@@ -184,79 +272,53 @@ func (l *List) add(db *DB, ui *UI) {
 	newItem := Item{
 		id:      id,
 		content: " ",
+		created: unixTime(now()),
 	}
 	nitems := len(l.items)
-	space := ui.listSpaceAvailable()
-	if nitems+1 <= space && ui.windowTop < space {
-		ui.windowBottom++
-	}
 	if nitems == 0 || nitems-1 == i {
 		l.items = append(l.items, newItem)
+		l.row = len(l.items) - 1
 		return
 	}
 	l.items = append(l.items[:i+1], l.items[i:]...)
 	l.items[i+1] = newItem
+	l.row = i + 1
 }
 
-func (l *List) addRune(r rune) {
-	if r == ' ' && l.col == 0 {
-		return
+// markItem toggles the current entry like updateItem stores its content.
+func (l *List) markItem(db *DB) bool {
+	if !l.hasCurrent() {
+		return false
 	}
-	content := l.currentItem().content
-	if len(content) == 1 && content[0] == ' ' {
-		l.items[l.row].content = string(r)
-	} else {
-		l.items[l.row].content = content[:l.col] + string(r) + content[l.col:]
-	}
-	l.col++
-}
-
-func (l *List) deleteRune() {
 	item := l.currentItem()
-	content := item.content
-	if l.col > 0 {
-		item.content = content[:l.col-1] + content[l.col:]
-		l.col--
-		if l.col == 0 && len(item.content) == 0 {
-			item.content = " "
-		}
-	}
-}
-
-func (l *List) addRuneToName(r rune) {
-	if r == ' ' && l.col == 0 {
-		return
+	item.done = !item.done
+	item.doneAt = unixTime(doneAt(item.done))
+	version, err := db.updateItemDone(item.id, item.done, item.version)
+	conflict := errors.Is(err, errConflict)
+	if conflict {
+		version, err = db.updateItemDone(item.id, item.done, -1)
 	}
-	if len(l.name) == 1 && l.name[0] == ' ' {
-		l.name = string(r)
-	} else {
-		l.name = l.name[:l.col] + string(r) + l.name[l.col:]
+	if err == nil {
+		item.version = version
 	}
-	l.col++
+	l.fixRow()
+	return conflict
 }
 
-func (l *List) deleteRuneFromName() {
-	if l.col > 0 {
-		l.name = l.name[:l.col-1] + l.name[l.col:]
-		l.col--
-		if l.col == 0 && len(l.name) == 0 {
-			l.name = " "
-		}
+// updateItem stores the content of the current entry, overwriting a
+// concurrent change made by another process. The returned bool reports
+// whether that happened.
+func (l *List) updateItem(db *DB) bool {
+	item := l.currentItem()
+	version, err := db.updateItemContent(item.id, item.content, item.version)
+	conflict := errors.Is(err, errConflict)
+	if conflict {
+		version, err = db.updateItemContent(item.id, item.content, -1)
 	}
-}
-
-func (l *List) markItem(db *DB) {
-	if len(l.items) == 0 {
-		return
+	if err == nil {
+		item.version = version
 	}
-	item := l.currentItem()
-	item.done = !item.done
-	db.updateItemDone(item.id, item.done)
-}
-
-func (l *List) updateItem(db *DB) {
-	item := l.currentItem()
-	db.updateItemContent(item.id, item.content)
+	return conflict
 }
 
 func (l *List) currentItem() *Item {
@@ -271,34 +333,3 @@ func (l *List) itemById(id int) *Item {
 	}
 	return nil
 }
-
-func (l *List) cursorLeftEntry() {
-	if l.col > 0 {
-		l.col--
-	}
-}
-
-func (l *List) cursorRightEntry() {
-    content := l.currentItem().content
-    if len(content) == 1 && content[0] == ' ' {
-        return
-    } 
-	if l.col < len(content) {
-		l.col++
-	}
-}
-
-func (l *List) cursorLeftListName() {
-	if l.col > 0 {
-		l.col--
-	}
-}
-
-func (l *List) cursorRightListName() {
-    if len(l.name) == 1 && l.name[0] == ' ' {
-        return
-    } 
-	if l.col < len(l.name) {
-		l.col++
-	}
-}
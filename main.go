@@ -2,19 +2,48 @@ package main
 
 import (
 	"flag"
+	"log"
 	"os"
 )
 
 var cFlag = flag.Bool("controls", false, "set to print controls overview")
+var retentionFlag = flag.Int("backup-retention", -1, "number of daily backups to keep, 0 disables them (default from the config file, 7)")
 
 func main() {
 	flag.Parse()
 
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if *retentionFlag < 0 {
+		*retentionFlag = config.backupRetention
+	}
+
 	if *cFlag {
 		printKeymaps()
 		return
 	}
 
+	var command func([]string) error
+	switch flag.Arg(0) {
+	case "serve":
+		command = runServe
+	case "backup":
+		command = runBackup
+	case "restore":
+		command = runRestore
+	case "encrypt":
+		command = runEncrypt
+	case "stats":
+		command = runStats
+	}
+	if command != nil {
+		if err := command(flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	var debug bool
 	if len(os.Args) > 1 {
 		debug = os.Args[1] == "debug"
@@ -28,6 +57,11 @@ func main() {
 		os.Exit(0)
 	}
 
+	if err := autoBackup(ui.db, *retentionFlag); err != nil {
+		ui.notify("Backup failed: " + err.Error())
+	}
+	ui.watch()
+
 	for {
 		ui.clear()
 		ui.render()
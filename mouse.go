@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// doubleClick is the longest time between two clicks on an entry that
+// starts editing it.
+const doubleClick = 400 * time.Millisecond
+
+// mouseState tracks the button between events, tcell reports the buttons
+// held down with every event instead of presses and releases.
+type mouseState struct {
+	buttons   tcell.ButtonMask
+	dragging  bool
+	moved     bool
+	lastClick time.Time
+	lastItem  int
+}
+
+func (ui *UI) handleMouse(ev *tcell.EventMouse) {
+	x, y := ev.Position()
+	buttons := ev.Buttons()
+	pressed := buttons&tcell.Button1 != 0 && ui.mouse.buttons&tcell.Button1 == 0
+	released := buttons&tcell.Button1 == 0 && ui.mouse.buttons&tcell.Button1 != 0
+	ui.mouse.buttons = buttons
+
+	if ui.mode != normalMode || ui.layout == boardLayout || x >= ui.listWidth() {
+		return
+	}
+	switch {
+	case buttons&tcell.WheelDown != 0:
+		ui.scroll(1)
+	case buttons&tcell.WheelUp != 0:
+		ui.scroll(-1)
+	case pressed:
+		ui.click(x, y)
+	case released:
+		if ui.mouse.moved {
+			ui.saveItemOrder()
+		}
+		ui.mouse.dragging, ui.mouse.moved = false, false
+	case ui.mouse.dragging:
+		ui.drag(y)
+	}
+}
+
+func (ui *UI) click(x, y int) {
+	if y == 1 {
+		for i, start := range ui.navPositions() {
+			if x >= start && x < start+len(strconv.Itoa(i+1)) {
+				ui.switchList(i)
+			}
+		}
+		return
+	}
+	l := ui.currentList()
+	i, line, ok := ui.itemAt(y)
+	if !ok {
+		return
+	}
+	l.row = i
+	ui.calculateWindow()
+	// The checkbox is only drawn on the first line of a wrapped entry.
+	if line == 0 && x >= leftOffset && x <= leftOffset+2 {
+		ui.listMarkEntry()
+		return
+	}
+	id := l.items[i].id
+	if id == ui.mouse.lastItem && time.Since(ui.mouse.lastClick) < doubleClick {
+		ui.mouse.lastClick = time.Time{}
+		ui.enterEdit()
+		return
+	}
+	ui.mouse.lastClick, ui.mouse.lastItem = time.Now(), id
+	// Entries of a list that is kept sorted or of a smart list stay in
+	// place.
+	ui.mouse.dragging = l.sortOrder.key == "" && !l.smart()
+}
+
+// drag moves the entry being dragged to the row under the pointer.
+func (ui *UI) drag(y int) {
+	l := ui.currentList()
+	i, _, ok := ui.itemAt(y)
+	if !ok {
+		return
+	}
+	for l.row < i {
+		l.switchDown(ui)
+		ui.mouse.moved = true
+	}
+	for l.row > i {
+		l.switchUp(ui)
+		ui.mouse.moved = true
+	}
+}
+
+// itemAt returns the index of the entry shown at screen row y and which of
+// its wrapped lines is there.
+func (ui *UI) itemAt(y int) (int, int, bool) {
+	l := ui.currentList()
+	if l == nil {
+		return 0, 0, false
+	}
+	top := topOffset + headerHeight
+	visible := l.visible()
+	completed := l.completedAt()
+	for p := ui.windowTop; p < ui.windowBottom; p++ {
+		if p == completed {
+			top++
+		}
+		h := ui.rowHeight(l, visible[p])
+		if y >= top && y < top+h {
+			return visible[p], y - top, true
+		}
+		top += h
+	}
+	return 0, 0, false
+}
+
+// scroll moves the window by n entries, taking the cursor along if it would
+// leave the window.
+func (ui *UI) scroll(n int) {
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() {
+		return
+	}
+	visible := l.visible()
+	heights := ui.windowHeights(l)
+	top := max(min(ui.windowTop+n, len(visible)-1), 0)
+	space := ui.listSpaceAvailable()
+	bottom, used := top, 0
+	for bottom < len(visible) && used+heights[bottom] <= space {
+		used += heights[bottom]
+		bottom++
+	}
+	if bottom == len(visible) && n > 0 && ui.windowBottom == len(visible) {
+		return
+	}
+	ui.windowTop = top
+	if p := l.position(l.row); p < top {
+		l.row = visible[top]
+	} else if p >= bottom {
+		l.row = visible[max(bottom-1, top)]
+	}
+	ui.calculateWindow()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClickCheckboxOfWrappedEntry(t *testing.T) {
+	ui := newTestUI(t)
+	id := mustItem(t, ui.db, mustList(t, ui.db))
+	if _, err := ui.db.updateItemContent(id, strings.Repeat("long entry ", 20), -1); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	top := topOffset + headerHeight
+	if n := ui.rowHeight(ui.currentList(), 0); n < 2 {
+		t.Fatalf("entry takes %d lines, want it wrapped", n)
+	}
+
+	ui.click(leftOffset+1, top+1)
+	if ui.currentList().items[0].done {
+		t.Error("a click on the second line of the entry toggled it")
+	}
+	ui.click(leftOffset+1, top)
+	if !ui.currentList().items[0].done {
+		t.Error("a click on the checkbox did not toggle the entry")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// bracketedPaste collects the keys between the start and the end of a paste
+// so it can be inserted at once instead of key by key.
+type bracketedPaste struct {
+	active bool
+	text   strings.Builder
+}
+
+func (ui *UI) handlePasteEvent(ev *tcell.EventPaste) {
+	if ev.Start() {
+		ui.bracketed.active = true
+		ui.bracketed.text.Reset()
+		return
+	}
+	ui.bracketed.active = false
+	ui.insertPaste(ui.bracketed.text.String())
+}
+
+func (ui *UI) addPastedKey(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyRune:
+		ui.bracketed.text.WriteRune(ev.Rune())
+	case tcell.KeyEnter:
+		ui.bracketed.text.WriteRune('\n')
+	case tcell.KeyTab:
+		ui.bracketed.text.WriteRune('\t')
+	}
+}
+
+// insertPaste inserts pasted text into the editor of the current mode. In
+// the normal mode every line becomes an entry below the current one.
+func (ui *UI) insertPaste(text string) {
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	switch ui.mode {
+	case editMode:
+		lines := strings.Split(text, "\n")
+		if !config.splitPaste || len(lines) == 1 {
+			ui.editor.insertText(joinLines(text))
+			ui.syncEdit()
+			return
+		}
+		ui.editor.insertText(strings.TrimSpace(lines[0]))
+		ui.syncEdit()
+		ui.exitEdit()
+		l := ui.currentList()
+		if items := parseEntries(strings.Join(lines[1:], "\n")); len(items) != 0 {
+			if err := ui.insertItems(l, items, l.row+1); err != nil {
+				ui.fail(err)
+			}
+		}
+	case editListNameMode:
+		ui.editor.insertText(joinLines(text))
+		ui.syncEdit()
+	case commandMode:
+		ui.command.input += joinLines(text)
+		ui.command.completions = nil
+	case passphraseMode:
+		ui.passphrase += text
+	case normalMode:
+		l := ui.currentList()
+		if l == nil {
+			return
+		}
+		items := parseEntries(text)
+		if len(items) == 0 {
+			return
+		}
+		pos := 0
+		if len(l.items) != 0 {
+			pos = l.row + 1
+		}
+		if err := ui.insertItems(l, items, pos); err != nil {
+			ui.fail(err)
+		}
+	}
+}
+
+// joinLines puts a multi-line paste on one line.
+func joinLines(text string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(text, "\t", " ")), " ")
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Server struct {
+	db    *DB
+	token string
+}
+
+type listJSON struct {
+	ID      int        `json:"id"`
+	Name    string     `json:"name"`
+	Version int        `json:"version"`
+	Items   []itemJSON `json:"items"`
+}
+
+type itemJSON struct {
+	ID      int    `json:"id"`
+	ListID  int    `json:"list_id"`
+	Content string `json:"content"`
+	Done    bool   `json:"done"`
+	Version int    `json:"version"`
+}
+
+// The optional version turns a write into a conditional one that fails with
+// 409 Conflict if the row was changed since the client read it.
+type listRequest struct {
+	Name    *string `json:"name"`
+	Version *int    `json:"version"`
+}
+
+type itemRequest struct {
+	Content *string `json:"content"`
+	Done    *bool   `json:"done"`
+	Version *int    `json:"version"`
+}
+
+type orderRequest struct {
+	IDs []int `json:"ids"`
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:7070", "address to listen on")
+	token := fs.String("token", os.Getenv("TODO_API_TOKEN"), "bearer token required by every request (default $TODO_API_TOKEN)")
+	fs.Parse(args)
+
+	db, err := openUnlockedDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.close()
+
+	log.Printf("serving on http://%s", *addr)
+	return http.ListenAndServe(*addr, newServer(db, *token))
+}
+
+func newServer(db *DB, token string) *Server {
+	return &Server{db: db, token: token}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "lists":
+		s.handleLists(w, r)
+	case len(parts) == 2 && parts[0] == "lists" && parts[1] == "order":
+		s.handleListOrder(w, r)
+	case len(parts) == 2 && parts[0] == "lists":
+		s.withID(w, parts[1], r, s.handleList)
+	case len(parts) == 3 && parts[0] == "lists" && parts[2] == "items":
+		s.withID(w, parts[1], r, s.handleListItems)
+	case len(parts) == 4 && parts[0] == "lists" && parts[2] == "items" && parts[3] == "order":
+		s.withID(w, parts[1], r, s.handleItemOrder)
+	case len(parts) == 2 && parts[0] == "items":
+		s.withID(w, parts[1], r, s.handleItem)
+	case len(parts) == 3 && parts[0] == "items" && parts[2] == "toggle":
+		s.withID(w, parts[1], r, s.handleItemToggle)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	given := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) == 1
+}
+
+func (s *Server) withID(w http.ResponseWriter, raw string, r *http.Request, handler func(http.ResponseWriter, *http.Request, int)) {
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	handler(w, r, id)
+}
+
+func (s *Server) handleLists(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		lists, err := s.db.loadLists()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		out := []listJSON{}
+		for _, l := range lists {
+			out = append(out, toListJSON(l))
+		}
+		writeJSON(w, http.StatusOK, out)
+	case http.MethodPost:
+		var req listRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		lists, err := s.db.loadLists()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(lists) >= maxLists {
+			writeError(w, http.StatusConflict, "list limit reached")
+			return
+		}
+		id, err := s.db.createList()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		list := List{ID: id, name: "List name"}
+		if req.Name != nil {
+			list.name = *req.Name
+			if list.version, err = s.db.updateListName(list.name, id, -1); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if _, err := s.db.saveListOrder(append(lists, list)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, toListJSON(list))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleListOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req orderRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	lists, err := s.db.loadLists()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	ordered, ok := reorderLists(lists, req.IDs)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ids must contain every list exactly once")
+		return
+	}
+	if _, err := s.db.saveListOrder(ordered); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	out := []listJSON{}
+	for _, l := range ordered {
+		out = append(out, toListJSON(l))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request, id int) {
+	lists, err := s.db.loadLists()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	i := listIndex(lists, id)
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+	list := lists[i]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, toListJSON(list))
+	case http.MethodPatch:
+		var req listRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Name != nil {
+			list.name = *req.Name
+			list.version, err = s.db.updateListName(list.name, id, versionOf(req.Version))
+			if errors.Is(err, errConflict) {
+				writeError(w, http.StatusConflict, "list was changed, current version is "+strconv.Itoa(list.version))
+				return
+			} else if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, toListJSON(list))
+	case http.MethodDelete:
+		if err := s.db.deleteList(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		remaining := append(lists[:i:i], lists[i+1:]...)
+		if _, err := s.db.saveListOrder(remaining); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleListItems(w http.ResponseWriter, r *http.Request, listID int) {
+	lists, err := s.db.loadLists()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	i := listIndex(lists, listID)
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+	list := lists[i]
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, toListJSON(list).Items)
+	case http.MethodPost:
+		var req itemRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		id, err := s.db.createItem(listID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		item := Item{id: id, content: "New Entry"}
+		if req.Content != nil {
+			item.content = *req.Content
+			if item.version, err = s.db.updateItemContent(id, item.content, -1); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if req.Done != nil && *req.Done {
+			item.done = true
+			if item.version, err = s.db.updateItemDone(id, true, -1); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		list.items = append(list.items, item)
+		if _, err := s.db.saveItemOrder(&list); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, toItemJSON(item, listID))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleItemOrder(w http.ResponseWriter, r *http.Request, listID int) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req orderRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	lists, err := s.db.loadLists()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	i := listIndex(lists, listID)
+	if i == -1 {
+		writeError(w, http.StatusNotFound, "list not found")
+		return
+	}
+	list := lists[i]
+	items, ok := reorderItems(list.items, req.IDs)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "ids must contain every entry of the list exactly once")
+		return
+	}
+	list.items = items
+	if _, err := s.db.saveItemOrder(&list); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toListJSON(list))
+}
+
+func (s *Server) handleItem(w http.ResponseWriter, r *http.Request, id int) {
+	item, listID, err := s.db.getItem(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "entry not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, toItemJSON(item, listID))
+	case http.MethodPatch:
+		var req itemRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		if req.Content != nil {
+			item.content = *req.Content
+			item.version, err = s.db.updateItemContent(id, item.content, versionOf(req.Version))
+			if errors.Is(err, errConflict) {
+				writeError(w, http.StatusConflict, "entry was changed, current version is "+strconv.Itoa(item.version))
+				return
+			} else if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if req.Done != nil {
+			// A version that was checked by the content update is
+			// used up.
+			version := versionOf(req.Version)
+			if req.Content != nil {
+				version = -1
+			}
+			item.done = *req.Done
+			item.version, err = s.db.updateItemDone(id, item.done, version)
+			if errors.Is(err, errConflict) {
+				writeError(w, http.StatusConflict, "entry was changed, current version is "+strconv.Itoa(item.version))
+				return
+			} else if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, toItemJSON(item, listID))
+	case http.MethodDelete:
+		if err := s.db.deleteItem(id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) handleItemToggle(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	item, listID, err := s.db.getItem(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "entry not found")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	item.done = !item.done
+	item.version, err = s.db.updateItemDone(id, item.done, item.version)
+	if errors.Is(err, errConflict) {
+		writeError(w, http.StatusConflict, "entry was changed, current version is "+strconv.Itoa(item.version))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toItemJSON(item, listID))
+}
+
+func versionOf(v *int) int {
+	if v == nil {
+		return -1
+	}
+	return *v
+}
+
+func listIndex(lists []List, id int) int {
+	for i := range lists {
+		if lists[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func reorderLists(lists []List, ids []int) ([]List, bool) {
+	if len(ids) != len(lists) {
+		return nil, false
+	}
+	var ordered []List
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		i := listIndex(lists, id)
+		if i == -1 || seen[id] {
+			return nil, false
+		}
+		seen[id] = true
+		ordered = append(ordered, lists[i])
+	}
+	return ordered, true
+}
+
+func reorderItems(items []Item, ids []int) ([]Item, bool) {
+	if len(ids) != len(items) {
+		return nil, false
+	}
+	var ordered []Item
+	seen := make(map[int]bool)
+	for _, id := range ids {
+		found := false
+		for _, item := range items {
+			if item.id == id && !seen[id] {
+				ordered = append(ordered, item)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		seen[id] = true
+	}
+	return ordered, true
+}
+
+func toListJSON(l List) listJSON {
+	out := listJSON{ID: l.ID, Name: l.name, Version: l.version, Items: []itemJSON{}}
+	for _, item := range l.items {
+		out.Items = append(out.Items, toItemJSON(item, l.ID))
+	}
+	return out
+}
+
+func toItemJSON(item Item, listID int) itemJSON {
+	return itemJSON{ID: item.id, ListID: listID, Content: item.content, Done: item.done, Version: item.version}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const testToken = "secret"
+
+// newTestDB opens a fresh database in a temporary directory.
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := openDatabase(filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.close)
+	if err := db.init(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func newTestClient(t *testing.T) *testClient {
+	server := httptest.NewServer(newServer(newTestDB(t), testToken))
+	t.Cleanup(server.Close)
+	return &testClient{t: t, server: server}
+}
+
+// request sends body as JSON with the test token, decodes the response into
+// out if given and returns the status code.
+func (c *testClient) request(method, path string, body, out any) int {
+	c.t.Helper()
+	return c.requestWithAuth(method, path, "Bearer "+testToken, body, out)
+}
+
+func (c *testClient) requestWithAuth(method, path, auth string, body, out any) int {
+	c.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, c.server.URL+path, &buf)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (c *testClient) createList(name string) listJSON {
+	c.t.Helper()
+	var l listJSON
+	if status := c.request("POST", "/lists", map[string]any{"name": name}, &l); status != http.StatusCreated {
+		c.t.Fatalf("creating list: status %d", status)
+	}
+	return l
+}
+
+func (c *testClient) createItem(listID int, content string) itemJSON {
+	c.t.Helper()
+	var item itemJSON
+	path := fmt.Sprintf("/lists/%d/items", listID)
+	if status := c.request("POST", path, map[string]any{"content": content}, &item); status != http.StatusCreated {
+		c.t.Fatalf("creating entry: status %d", status)
+	}
+	return item
+}
+
+func (c *testClient) contents(listID int) []string {
+	c.t.Helper()
+	var items []itemJSON
+	if status := c.request("GET", fmt.Sprintf("/lists/%d/items", listID), nil, &items); status != http.StatusOK {
+		c.t.Fatalf("getting entries: status %d", status)
+	}
+	contents := []string{}
+	for _, item := range items {
+		contents = append(contents, item.Content)
+	}
+	return contents
+}
+
+func TestServerAuthorization(t *testing.T) {
+	c := newTestClient(t)
+	for _, auth := range []string{"", "Bearer wrong", "Basic " + testToken, testToken} {
+		if status := c.requestWithAuth("GET", "/lists", auth, nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want %d", auth, status, http.StatusUnauthorized)
+		}
+	}
+	if status := c.request("GET", "/lists", nil, nil); status != http.StatusOK {
+		t.Errorf("valid token: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestServerWithoutToken(t *testing.T) {
+	server := httptest.NewServer(newServer(newTestDB(t), ""))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/lists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestServerLists(t *testing.T) {
+	c := newTestClient(t)
+	work := c.createList("Work")
+	c.createList("Home")
+
+	var lists []listJSON
+	if status := c.request("GET", "/lists", nil, &lists); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if len(lists) != 2 || lists[0].Name != "Work" || lists[1].Name != "Home" {
+		t.Fatalf("lists = %+v", lists)
+	}
+
+	path := fmt.Sprintf("/lists/%d", work.ID)
+	var renamed listJSON
+	status := c.request("PATCH", path, map[string]any{"name": "Job", "version": work.Version}, &renamed)
+	if status != http.StatusOK || renamed.Name != "Job" {
+		t.Fatalf("rename: status %d, list %+v", status, renamed)
+	}
+	if status := c.request("PATCH", path, map[string]any{"name": "Stale", "version": work.Version}, nil); status != http.StatusConflict {
+		t.Errorf("stale rename: status %d, want %d", status, http.StatusConflict)
+	}
+	var got listJSON
+	if c.request("GET", path, nil, &got); got.Name != "Job" {
+		t.Errorf("name = %q, want Job", got.Name)
+	}
+
+	if status := c.request("DELETE", path, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete: status %d", status)
+	}
+	if status := c.request("GET", path, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted list: status %d, want %d", status, http.StatusNotFound)
+	}
+	if c.request("GET", "/lists", nil, &lists); len(lists) != 1 || lists[0].Name != "Home" {
+		t.Errorf("lists after delete = %+v", lists)
+	}
+}
+
+func TestServerItems(t *testing.T) {
+	c := newTestClient(t)
+	l := c.createList("Work")
+	item := c.createItem(l.ID, "report")
+	if item.ListID != l.ID || item.Done {
+		t.Fatalf("created %+v", item)
+	}
+
+	path := fmt.Sprintf("/items/%d", item.ID)
+	var edited itemJSON
+	status := c.request("PATCH", path, map[string]any{"content": "write report", "version": item.Version}, &edited)
+	if status != http.StatusOK || edited.Content != "write report" {
+		t.Fatalf("edit: status %d, entry %+v", status, edited)
+	}
+	if status := c.request("PATCH", path, map[string]any{"content": "stale", "version": item.Version}, nil); status != http.StatusConflict {
+		t.Errorf("stale edit: status %d, want %d", status, http.StatusConflict)
+	}
+	var done itemJSON
+	if c.request("PATCH", path, map[string]any{"done": true}, &done); !done.Done {
+		t.Errorf("done = false after PATCH")
+	}
+
+	var got itemJSON
+	if status := c.request("GET", path, nil, &got); status != http.StatusOK || got.Content != "write report" || !got.Done {
+		t.Errorf("get: status %d, entry %+v", status, got)
+	}
+
+	if status := c.request("DELETE", path, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete: status %d", status)
+	}
+	if status := c.request("GET", path, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted entry: status %d, want %d", status, http.StatusNotFound)
+	}
+	if status := c.request("GET", "/lists/999/items", nil, nil); status != http.StatusNotFound {
+		t.Errorf("missing list: status %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestServerToggle(t *testing.T) {
+	c := newTestClient(t)
+	l := c.createList("Work")
+	item := c.createItem(l.ID, "report")
+	path := fmt.Sprintf("/items/%d/toggle", item.ID)
+	for _, want := range []bool{true, false} {
+		var toggled itemJSON
+		if status := c.request("POST", path, nil, &toggled); status != http.StatusOK || toggled.Done != want {
+			t.Fatalf("toggle: status %d, done %v, want %v", status, toggled.Done, want)
+		}
+	}
+	if status := c.request("GET", path, nil, nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET toggle: status %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServerReorder(t *testing.T) {
+	c := newTestClient(t)
+	work := c.createList("Work")
+	home := c.createList("Home")
+	a := c.createItem(work.ID, "a")
+	b := c.createItem(work.ID, "b")
+	d := c.createItem(work.ID, "c")
+
+	path := fmt.Sprintf("/lists/%d/items/order", work.ID)
+	if status := c.request("PUT", path, map[string]any{"ids": []int{d.ID, a.ID, b.ID}}, nil); status != http.StatusOK {
+		t.Fatalf("reorder entries: status %d", status)
+	}
+	if got := fmt.Sprint(c.contents(work.ID)); got != "[c a b]" {
+		t.Errorf("entries = %s, want [c a b]", got)
+	}
+	if status := c.request("PUT", path, map[string]any{"ids": []int{a.ID, a.ID, b.ID}}, nil); status != http.StatusBadRequest {
+		t.Errorf("duplicate ids: status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status := c.request("PUT", "/lists/order", map[string]any{"ids": []int{home.ID, work.ID}}, nil); status != http.StatusOK {
+		t.Fatalf("reorder lists: status %d", status)
+	}
+	var lists []listJSON
+	if c.request("GET", "/lists", nil, &lists); len(lists) != 2 || lists[0].ID != home.ID {
+		t.Errorf("lists = %+v, want Home first", lists)
+	}
+	if status := c.request("PUT", "/lists/order", map[string]any{"ids": []int{home.ID}}, nil); status != http.StatusBadRequest {
+		t.Errorf("missing list id: status %d, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestServerDeleteKeepsOrder(t *testing.T) {
+	c := newTestClient(t)
+	l := c.createList("Work")
+	a := c.createItem(l.ID, "a")
+	b := c.createItem(l.ID, "b")
+	d := c.createItem(l.ID, "c")
+	path := fmt.Sprintf("/lists/%d/items/order", l.ID)
+	c.request("PUT", path, map[string]any{"ids": []int{d.ID, b.ID, a.ID}}, nil)
+	if status := c.request("DELETE", fmt.Sprintf("/items/%d", b.ID), nil, nil); status != http.StatusNoContent {
+		t.Fatalf("delete: status %d", status)
+	}
+	if got := fmt.Sprint(c.contents(l.ID)); got != "[c a]" {
+		t.Errorf("entries = %s, want [c a]", got)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const queryUsage = "[-]<word|#tag|!|open|done|due:<when>|done:today|done:week|list:<name>>..."
+
+var errSmartList = errors.New("a smart list only shows the entries of other lists")
+
+// smartList is a saved query. Its list gets the ID -id, so it can't be taken
+// for a real list.
+type smartList struct {
+	id    int
+	name  string
+	query string
+}
+
+func (db *DB) createSmartList(name, query string) (int, error) {
+	sealedName, err := db.seal(name)
+	if err != nil {
+		return -1, err
+	}
+	sealedQuery, err := db.seal(query)
+	if err != nil {
+		return -1, err
+	}
+	var id int
+	row := db.db.QueryRow("INSERT INTO smart_list (id, name, query) VALUES (null, ?, ?) RETURNING id", sealedName, sealedQuery)
+	if err := row.Scan(&id); err != nil {
+		return -1, err
+	}
+	return id, nil
+}
+
+func (db *DB) getSmartLists() ([]smartList, error) {
+	rows, err := db.db.Query("SELECT id, name, query FROM smart_list ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lists []smartList
+	for rows.Next() {
+		var s smartList
+		if err := rows.Scan(&s.id, &s.name, &s.query); err != nil {
+			return nil, err
+		}
+		if s.name, err = db.unseal(s.name); err != nil {
+			return nil, err
+		}
+		if s.query, err = db.unseal(s.query); err != nil {
+			return nil, err
+		}
+		lists = append(lists, s)
+	}
+	return lists, rows.Err()
+}
+
+func (db *DB) updateSmartList(id int, column, value string) error {
+	sealed, err := db.seal(value)
+	if err != nil {
+		return err
+	}
+	_, err = db.db.Exec(fmt.Sprintf("UPDATE smart_list SET %s = ? WHERE id = ?", column), sealed, id)
+	return err
+}
+
+func (db *DB) deleteSmartList(id int) error {
+	_, err := db.db.Exec("DELETE FROM smart_list WHERE id = ?", id)
+	return err
+}
+
+// smart reports whether l is a smart list, its entries are copies of the
+// matching entries of the other lists.
+func (l *List) smart() bool {
+	return l.query != ""
+}
+
+// filter tells whether an entry of the list named owner matches a term of a
+// query.
+type filter func(item *Item, owner string, today time.Time) bool
+
+// parseQuery reads a query, an entry matches when it matches all its terms.
+// A term starting with - matches the entries the rest does not match.
+func parseQuery(q string) ([]filter, error) {
+	var filters []filter
+	for _, term := range strings.Fields(q) {
+		negated := strings.HasPrefix(term, "-") && len(term) > 1
+		if negated {
+			term = term[1:]
+		}
+		f, err := parseTerm(strings.ToLower(term))
+		if err != nil {
+			return nil, err
+		}
+		if negated {
+			f = not(f)
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 0 {
+		return nil, errors.New("the query is empty")
+	}
+	return filters, nil
+}
+
+func parseTerm(term string) (filter, error) {
+	key, value, hasValue := strings.Cut(term, ":")
+	switch {
+	case term == "open":
+		return func(item *Item, owner string, today time.Time) bool {
+			return !item.done
+		}, nil
+	case term == "done":
+		return func(item *Item, owner string, today time.Time) bool {
+			return item.done
+		}, nil
+	case strings.HasPrefix(term, "#") && len(term) > 1:
+		return func(item *Item, owner string, today time.Time) bool {
+			for _, tag := range item.tags {
+				if strings.EqualFold(tag, term[1:]) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case strings.Trim(term, "!") == "" && len(term) <= maxPriority:
+		return func(item *Item, owner string, today time.Time) bool {
+			return item.priority >= len(term)
+		}, nil
+	case hasValue && key == "due":
+		return dueFilter(value)
+	case hasValue && key == "done":
+		return doneFilter(value)
+	case hasValue && key == "list":
+		return func(item *Item, owner string, today time.Time) bool {
+			return strings.HasPrefix(strings.ToLower(owner), value)
+		}, nil
+	}
+	return func(item *Item, owner string, today time.Time) bool {
+		return strings.Contains(strings.ToLower(item.content), term)
+	}, nil
+}
+
+// dueFilter matches due dates by their agenda group, week takes in all days
+// from today to the end of the week.
+func dueFilter(when string) (filter, error) {
+	groups := map[string][]int{
+		"overdue":  {overdueGroup},
+		"today":    {todayGroup},
+		"tomorrow": {tomorrowGroup},
+		"week":     {todayGroup, tomorrowGroup, weekGroup},
+		"later":    {laterGroup},
+	}
+	switch when {
+	case "any", "none":
+		return func(item *Item, owner string, today time.Time) bool {
+			return (item.due != "") == (when == "any")
+		}, nil
+	}
+	matching, ok := groups[when]
+	if !ok {
+		return nil, errors.New("usage: due:overdue|today|tomorrow|week|later|any|none")
+	}
+	return func(item *Item, owner string, today time.Time) bool {
+		if item.due == "" {
+			return false
+		}
+		group := dueGroup(item.due, today)
+		for _, g := range matching {
+			if g == group {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// doneFilter matches the entries completed today or this week.
+func doneFilter(when string) (filter, error) {
+	if when != "today" && when != "week" {
+		return nil, errors.New("usage: done:today|week")
+	}
+	return func(item *Item, owner string, today time.Time) bool {
+		since := today
+		if when == "week" {
+			since = today.AddDate(0, 0, -weekday(today))
+		}
+		return item.done && !item.doneAt.Before(since)
+	}, nil
+}
+
+func not(f filter) filter {
+	return func(item *Item, owner string, today time.Time) bool {
+		return !f(item, owner, today)
+	}
+}
+
+// collect fills the smart list l with copies of the matching entries of
+// lists, keeping the cursor on the same entry.
+func (l *List) collect(lists []List) {
+	filters, err := parseQuery(l.query)
+	current := -1
+	if len(l.items) != 0 {
+		current = l.currentItem().id
+	}
+	l.items = nil
+	today := startOfDay(time.Now())
+	for _, owner := range lists {
+		if owner.smart() || err != nil {
+			continue
+		}
+	items:
+		for _, item := range owner.items {
+			for _, f := range filters {
+				if !f(&item, owner.name, today) {
+					continue items
+				}
+			}
+			item.owner = owner.name
+			l.items = append(l.items, item)
+		}
+	}
+	if i := indexOf(l, current); i != -1 {
+		l.row = i
+	}
+	l.row = min(l.row, max(len(l.items)-1, 0))
+	l.fixRow()
+}
+
+// loadLists reads the lists followed by the smart lists.
+func (ui *UI) loadLists() ([]List, error) {
+	lists, err := ui.db.loadLists()
+	if err != nil {
+		return nil, err
+	}
+	smartLists, err := ui.db.getSmartLists()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range smartLists {
+		l := List{ID: -s.id, name: s.name, query: s.query}
+		l.collect(lists)
+		lists = append(lists, l)
+	}
+	return lists, nil
+}
+
+// refreshSmartLists collects the entries of the smart lists anew after the
+// entries of the other lists changed.
+func (ui *UI) refreshSmartLists() {
+	for i := range ui.lists {
+		if ui.lists[i].smart() {
+			ui.lists[i].collect(ui.lists)
+		}
+	}
+	ui.calculateWindow()
+}
+
+// updateOwner copies the current entry of a smart list to the list it
+// belongs to, after it was changed in place.
+func (ui *UI) updateOwner() {
+	l := ui.currentList()
+	if l == nil || !l.smart() || !l.hasCurrent() {
+		return
+	}
+	changed := *l.currentItem()
+	changed.owner = ""
+	for i := range ui.lists {
+		if item := ui.lists[i].itemById(changed.id); item != nil && !ui.lists[i].smart() {
+			*item = changed
+		}
+	}
+}
+
+// changed brings the lists up to date after entries changed: kept sorted
+// lists are sorted again, the smart lists collect their entries anew and the
+// history of the details pane is read again.
+func (ui *UI) changed() {
+	ui.history.loaded = false
+	ui.keepSorted()
+	ui.refreshSmartLists()
+}
+
+// realLists returns the lists that are not smart lists.
+func (ui *UI) realLists() []List {
+	var lists []List
+	for _, l := range ui.lists {
+		if !l.smart() {
+			lists = append(lists, l)
+		}
+	}
+	return lists
+}
+
+// appendList adds a list after the other real lists, the smart lists always
+// come last.
+func (ui *UI) appendList(l List) int {
+	i := len(ui.lists)
+	for i > 0 && ui.lists[i-1].smart() {
+		i--
+	}
+	ui.lists = append(ui.lists[:i], append([]List{l}, ui.lists[i:]...)...)
+	return i
+}
+
+// cmdSmart adds a smart list for the query and lets its name be edited.
+func (ui *UI) cmdSmart(arg string) error {
+	if arg == "" {
+		return errors.New("usage: smart " + queryUsage)
+	}
+	if _, err := parseQuery(arg); err != nil {
+		return err
+	}
+	if len(ui.lists)-len(ui.realLists()) >= maxSmartLists {
+		return errors.New("there is no room for another smart list")
+	}
+	id, err := ui.db.createSmartList(arg, arg)
+	if err != nil {
+		return err
+	}
+	l := List{ID: -id, name: arg, query: arg}
+	l.collect(ui.lists)
+	ui.lists = append(ui.lists, l)
+	ui.current = len(ui.lists) - 1
+	ui.view = normalMode
+	ui.windowTop = 0
+	ui.calculateWindow()
+	ui.enterNameEdit()
+	return nil
+}
+
+// cmdQuery changes the query of the current smart list. Without one it
+// offers the current query for editing.
+func (ui *UI) cmdQuery(arg string) error {
+	l := ui.currentList()
+	if l == nil || !l.smart() {
+		return errors.New("the list is not a smart list, :smart adds one")
+	}
+	if arg == "" {
+		ui.promptCommand("query " + l.query)
+		return nil
+	}
+	if _, err := parseQuery(arg); err != nil {
+		return err
+	}
+	if err := ui.db.updateSmartList(-l.ID, "query", arg); err != nil {
+		return err
+	}
+	l.query = arg
+	l.collect(ui.lists)
+	ui.calculateWindow()
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestUI returns a UI on a simulated screen over a fresh database.
+func newTestUI(t *testing.T) *UI {
+	t.Helper()
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(80, 24)
+	t.Cleanup(screen.Fini)
+	return &UI{screen: screen, db: newTestDB(t), mode: normalMode, view: normalMode, splitRatio: 50}
+}
+
+func TestSmartListFollowsDeletes(t *testing.T) {
+	ui := newTestUI(t)
+	listID := mustList(t, ui.db)
+	var ids []int
+	for _, content := range []string{"write report", "buy milk"} {
+		id := mustItem(t, ui.db, listID)
+		if _, err := ui.db.updateItemContent(id, content, -1); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := ui.db.createSmartList("Reports", "report"); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	if len(ui.lists) != 2 || len(ui.lists[1].items) != 1 {
+		t.Fatalf("smart list before the delete = %+v", ui.lists[1].items)
+	}
+
+	ui.current = 0
+	ui.lists[0].row = indexOf(&ui.lists[0], ids[0])
+	ui.listDeleteEntry()
+	if items := ui.lists[1].items; len(items) != 0 {
+		t.Errorf("smart list still shows %q after the delete", items[0].content)
+	}
+}
+
+func TestSmartListFollowsRenames(t *testing.T) {
+	ui := newTestUI(t)
+	mustItem(t, ui.db, mustList(t, ui.db))
+	if _, err := ui.db.createSmartList("Work", "list:work"); err != nil {
+		t.Fatal(err)
+	}
+	ui.load()
+	if err := ui.cmdRename("Work"); err != nil {
+		t.Fatal(err)
+	}
+	smart := ui.lists[1]
+	if len(smart.items) != 1 || smart.items[0].owner != "Work" {
+		t.Errorf("smart list after the rename = %+v", smart.items)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	// A wednesday, the week goes on until sunday the 10th.
+	today := time.Date(2024, 3, 6, 0, 0, 0, 0, time.Local)
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	report := Item{content: "Write Report", tags: []string{"Work"}, priority: 2, due: "2024-03-06"}
+	tests := []struct {
+		query string
+		item  Item
+		owner string
+		want  bool
+	}{
+		{"report", report, "Home", true},
+		{"-report", report, "Home", false},
+		{"write report", report, "Home", true},
+		{"write milk", report, "Home", false},
+		{"#work", report, "Home", true},
+		{"#home", report, "Home", false},
+		{"-#work", report, "Home", false},
+		{"!!", report, "Home", true},
+		{"!!!", report, "Home", false},
+		{"open", report, "Home", true},
+		{"done", report, "Home", false},
+		{"list:wo", report, "Work", true},
+		{"list:wo", report, "Home", false},
+		{"-list:work", report, "Home", true},
+		{"due:today", report, "", true},
+		{"due:tomorrow", report, "", false},
+		{"due:overdue", Item{due: "2024-03-05"}, "", true},
+		{"due:week", Item{due: "2024-03-10"}, "", true},
+		{"due:week", Item{due: "2024-03-11"}, "", false},
+		{"due:later", Item{due: "2024-03-11"}, "", true},
+		{"due:week", Item{}, "", false},
+		{"due:none", Item{}, "", true},
+		{"due:any", report, "", true},
+		{"done:today", Item{done: true, doneAt: at(6, 10)}, "", true},
+		{"done:today", Item{done: true, doneAt: at(5, 23)}, "", false},
+		{"done:week", Item{done: true, doneAt: at(4, 8)}, "", true},
+		{"done:week", Item{done: true, doneAt: at(3, 20)}, "", false},
+		{"done:week", Item{doneAt: at(5, 8)}, "", false},
+		{"open #work -milk due:week", report, "", true},
+	}
+	for _, tt := range tests {
+		filters, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		got := true
+		for _, f := range filters {
+			got = got && f(&tt.item, tt.owner, today)
+		}
+		if got != tt.want {
+			t.Errorf("%q on %+v in %q = %v, want %v", tt.query, tt.item, tt.owner, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "  ", "due:soon", "done:month"} {
+		if _, err := parseQuery(query); err == nil {
+			t.Errorf("parseQuery(%q) accepted an invalid query", query)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// sortKeys compare two entries for each way a list can be sorted. Descending
+// sorts reverse them, entries without a due date stay last either way.
+var sortKeys = map[string]func(a, b Item) int{
+	"name": func(a, b Item) int {
+		return strings.Compare(strings.ToLower(a.content), strings.ToLower(b.content))
+	},
+	"done": func(a, b Item) int {
+		return compareBools(a.done, b.done)
+	},
+	"created": func(a, b Item) int {
+		switch {
+		case a.created.Before(b.created):
+			return -1
+		case a.created.After(b.created):
+			return 1
+		}
+		return 0
+	},
+	"due": func(a, b Item) int {
+		return strings.Compare(a.due, b.due)
+	},
+	"priority": func(a, b Item) int {
+		return b.priority - a.priority
+	},
+}
+
+const sortUsage = "name|done|created|due|priority [asc|desc]"
+
+// sortOrder is how a list is kept sorted, e.g. "due" or "name desc".
+type sortOrder struct {
+	key  string
+	desc bool
+}
+
+// parseSortOrder reads "[key] [asc|desc]", the key defaults to name.
+func parseSortOrder(arg string) (sortOrder, error) {
+	fields := strings.Fields(arg)
+	o := sortOrder{key: "name"}
+	if len(fields) > 0 {
+		if _, ok := sortKeys[fields[0]]; ok {
+			o.key = fields[0]
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		switch fields[0] {
+		case "asc":
+		case "desc":
+			o.desc = true
+		default:
+			return o, errors.New("usage: sort " + sortUsage)
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 0 {
+		return o, errors.New("usage: sort " + sortUsage)
+	}
+	return o, nil
+}
+
+func (o sortOrder) String() string {
+	if o.desc {
+		return o.key + " desc"
+	}
+	return o.key
+}
+
+// sortItems sorts the entries of l, keeping the cursor on the same entry. It
+// reports whether the order changed.
+func (l *List) sortItems(o sortOrder) bool {
+	compare := sortKeys[o.key]
+	if compare == nil || len(l.items) == 0 {
+		return false
+	}
+	before := make([]int, len(l.items))
+	for i := range l.items {
+		before[i] = l.items[i].id
+	}
+	current := l.currentItem().id
+	sort.SliceStable(l.items, func(i, j int) bool {
+		a, b := l.items[i], l.items[j]
+		if o.key == "due" && (a.due == "") != (b.due == "") {
+			return b.due == ""
+		}
+		if o.desc {
+			return compare(a, b) > 0
+		}
+		return compare(a, b) < 0
+	})
+	l.row = max(indexOf(l, current), 0)
+	for i := range l.items {
+		if l.items[i].id != before[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// keepSorted sorts the lists that are kept sorted and stores the orders that
+// changed.
+func (ui *UI) keepSorted() {
+	for i := range ui.lists {
+		l := &ui.lists[i]
+		if l.sortOrder.key != "" && l.sortItems(l.sortOrder) {
+			ui.saveItemOrderOf(l)
+		}
+	}
+	ui.calculateWindow()
+}
+
+// checkUnsorted reports lists that are kept sorted and smart lists, their
+// entries cannot be moved by hand.
+func (ui *UI) checkUnsorted() bool {
+	if l := ui.currentList(); l != nil && l.smart() {
+		ui.fail(errSmartList)
+		return false
+	}
+	if l := ui.currentList(); l != nil && l.sortOrder.key != "" {
+		ui.fail(errors.New("the list is kept sorted by " + l.sortOrder.String() + ", :keep-sorted off ends that"))
+		return false
+	}
+	return true
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseSortOrder(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want sortOrder
+	}{
+		{"", sortOrder{key: "name"}},
+		{"due", sortOrder{key: "due"}},
+		{"priority asc", sortOrder{key: "priority"}},
+		{"created desc", sortOrder{key: "created", desc: true}},
+		{"desc", sortOrder{key: "name", desc: true}},
+	}
+	for _, tt := range tests {
+		got, err := parseSortOrder(tt.arg)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSortOrder(%q) = %v, %v, want %v", tt.arg, got, err, tt.want)
+		}
+	}
+	for _, arg := range []string{"size", "due up", "name desc again"} {
+		if _, err := parseSortOrder(arg); err == nil {
+			t.Errorf("parseSortOrder(%q) accepted an invalid order", arg)
+		}
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 0, 0, 0, 0, time.Local)
+	}
+	items := []Item{
+		{id: 1, content: "banana", due: "2024-03-02", priority: 1, created: day(3)},
+		{id: 2, content: "Apple", done: true, priority: 3, created: day(1)},
+		{id: 3, content: "cherry", due: "2024-03-01", created: day(2)},
+		{id: 4, content: "date", done: true, due: "2024-03-03", priority: 1, created: day(4)},
+	}
+	tests := []struct {
+		order string
+		want  []int
+	}{
+		{"name", []int{2, 1, 3, 4}},
+		{"name desc", []int{4, 3, 1, 2}},
+		{"done", []int{1, 3, 2, 4}},
+		{"done desc", []int{2, 4, 1, 3}},
+		{"created", []int{2, 3, 1, 4}},
+		// entries without a due date stay last either way
+		{"due", []int{3, 1, 4, 2}},
+		{"due desc", []int{4, 1, 3, 2}},
+		// the highest priority comes first, equal ones keep their order
+		{"priority", []int{2, 1, 4, 3}},
+		{"priority desc", []int{3, 1, 4, 2}},
+	}
+	for _, tt := range tests {
+		o, err := parseSortOrder(tt.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := List{items: append([]Item(nil), items...), row: 2}
+		changed := l.sortItems(o)
+		if got := fmt.Sprint(itemIDs(l)); got != fmt.Sprint(tt.want) {
+			t.Errorf("sorted by %s = %s, want %v", tt.order, got, tt.want)
+		}
+		if l.currentItem().id != 3 {
+			t.Errorf("sorted by %s: cursor on %d, want it kept on 3", tt.order, l.currentItem().id)
+		}
+		if changed != (fmt.Sprint(tt.want) != "[1 2 3 4]") {
+			t.Errorf("sorted by %s: reported changed %v", tt.order, changed)
+		}
+	}
+}
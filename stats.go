@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	statsDays  = 14
+	statsWeeks = 8
+)
+
+// statsSection is a titled block of the statistics, shown in the stats view
+// and printed by todo stats.
+type statsSection struct {
+	title string
+	lines []string
+}
+
+type stats struct {
+	// perDay and perWeek count the completed entries, the last one is
+	// today or this week
+	perDay  []int
+	perWeek []int
+	streak  int
+	// average is the time from creating to completing an entry
+	average   time.Duration
+	averaged  int
+	done      int
+	total     int
+	lists     []listStats
+	firstDay  time.Time
+	firstWeek time.Time
+}
+
+type listStats struct {
+	name  string
+	done  int
+	total int
+}
+
+// computeStats counts the completions of the entries of lists. Entries
+// completed before completion times were recorded only count in the totals.
+func computeStats(lists []List, now time.Time) stats {
+	today := startOfDay(now)
+	s := stats{
+		perDay:    make([]int, statsDays),
+		perWeek:   make([]int, statsWeeks),
+		firstDay:  today.AddDate(0, 0, 1-statsDays),
+		firstWeek: today.AddDate(0, 0, -weekday(today)-7*(statsWeeks-1)),
+	}
+	doneDays := make(map[string]bool)
+	var total time.Duration
+	for _, l := range lists {
+		ls := listStats{name: l.name, total: len(l.items)}
+		for _, item := range l.items {
+			if !item.done {
+				continue
+			}
+			ls.done++
+			if item.doneAt.IsZero() {
+				continue
+			}
+			day := startOfDay(item.doneAt)
+			doneDays[day.Format(dateFormat)] = true
+			if i := daysBetween(s.firstDay, day); i >= 0 && i < statsDays {
+				s.perDay[i]++
+			}
+			if i := daysBetween(s.firstWeek, day) / 7; !day.Before(s.firstWeek) && i < statsWeeks {
+				s.perWeek[i]++
+			}
+			if !item.created.IsZero() && !item.doneAt.Before(item.created) {
+				total += item.doneAt.Sub(item.created)
+				s.averaged++
+			}
+		}
+		s.done += ls.done
+		s.total += ls.total
+		s.lists = append(s.lists, ls)
+	}
+	if s.averaged != 0 {
+		s.average = total / time.Duration(s.averaged)
+	}
+	// A streak that was continued yesterday still counts until today ends.
+	day := today
+	if !doneDays[day.Format(dateFormat)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for doneDays[day.Format(dateFormat)] {
+		s.streak++
+		day = day.AddDate(0, 0, -1)
+	}
+	return s
+}
+
+// sections lays the statistics out as text for the given width.
+func (s stats) sections(width int) []statsSection {
+	days := make([]string, statsDays)
+	for i := range days {
+		days[i] = s.firstDay.AddDate(0, 0, i).Format("Mon 02")
+	}
+	weeks := make([]string, statsWeeks)
+	for i := range weeks {
+		weeks[i] = s.firstWeek.AddDate(0, 0, 7*i).Format("Jan 02")
+	}
+	summary := []string{
+		fmt.Sprintf("Done:            %d of %d entries", s.done, s.total),
+		fmt.Sprintf("Current streak:  %s", plural(s.streak, "day")),
+	}
+	if s.averaged != 0 {
+		summary = append(summary, fmt.Sprintf("Time to done:    %s on average over %s", formatDuration(s.average), entries(s.averaged)))
+	} else {
+		summary = append(summary, "Time to done:    -")
+	}
+
+	var lists []string
+	nameWidth := 0
+	for _, l := range s.lists {
+		nameWidth = max(nameWidth, min(textWidth(l.name), width/3))
+	}
+	for _, l := range s.lists {
+		rate := 0
+		if l.total != 0 {
+			rate = 100 * l.done / l.total
+		}
+		lists = append(lists, fmt.Sprintf("%s %3d%%  %d of %d", padRight(l.name, nameWidth), rate, l.done, l.total))
+	}
+	if len(lists) == 0 {
+		lists = []string{"There are no lists"}
+	}
+
+	return []statsSection{
+		{"Summary", summary},
+		{"Done per day", barChart(days, s.perDay, width)},
+		{"Done per week", barChart(weeks, s.perWeek, width)},
+		{"Lists", lists},
+	}
+}
+
+// barChart draws one bar per label, scaled so the longest fills width.
+func barChart(labels []string, values []int, width int) []string {
+	highest := 1
+	for _, v := range values {
+		highest = max(highest, v)
+	}
+	labelWidth := 0
+	for _, l := range labels {
+		labelWidth = max(labelWidth, textWidth(l))
+	}
+	space := max(width-labelWidth-len(fmt.Sprint(highest))-2, 1)
+	lines := make([]string, len(values))
+	for i, v := range values {
+		lines[i] = fmt.Sprintf("%s %s %d", padRight(labels[i], labelWidth), strings.Repeat("█", v*space/highest), v)
+	}
+	return lines
+}
+
+// padRight pads or cuts s to width cells.
+func padRight(s string, width int) string {
+	var b strings.Builder
+	w := 0
+	for _, c := range graphemes(s) {
+		if w+clusterWidth(c) > width {
+			break
+		}
+		b.WriteString(c)
+		w += clusterWidth(c)
+	}
+	return b.String() + strings.Repeat(" ", width-w)
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d.Hours()), "hour")
+	}
+	return plural(int(d.Hours()/24), "day")
+}
+
+type statsView struct {
+	top int
+}
+
+func (ui *UI) openStats() {
+	ui.stats = statsView{}
+	ui.view = statsMode
+	ui.mode = statsMode
+}
+
+func (ui *UI) closeStats() {
+	ui.view = normalMode
+	ui.mode = normalMode
+}
+
+func (ui *UI) statsDown() {
+	ui.stats.top++
+}
+
+func (ui *UI) statsUp() {
+	ui.stats.top = max(ui.stats.top-1, 0)
+}
+
+func renderStats(ui *UI) {
+	width := ui.width() - 2*leftOffset
+	drawClipped(ui, leftOffset, 1, width, padChunk("Statistics"), lightDark)
+	var lines []string
+	var headings []bool
+	for i, section := range computeStats(ui.realLists(), time.Now()).sections(width) {
+		if i != 0 {
+			lines = append(lines, "")
+			headings = append(headings, false)
+		}
+		lines = append(lines, padChunk(section.title))
+		headings = append(headings, true)
+		for _, line := range section.lines {
+			lines = append(lines, line)
+			headings = append(headings, false)
+		}
+	}
+	top := 3
+	space := max(ui.height()-top-bottomOffset-footerHeight, 1)
+	ui.stats.top = max(min(ui.stats.top, len(lines)-space), 0)
+	for i := ui.stats.top; i < len(lines) && i < ui.stats.top+space; i++ {
+		y := top + i - ui.stats.top
+		if headings[i] {
+			drawClipped(ui, leftOffset, y, width, separator(ui, lines[i], 2*leftOffset), primaryLight)
+		} else {
+			drawClipped(ui, leftOffset, y, width, lines[i], darkLight)
+		}
+	}
+}
+
+func runStats(args []string) error {
+	db, err := openUnlockedDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.close()
+	lists, err := db.loadLists()
+	if err != nil {
+		return err
+	}
+	for i, section := range computeStats(lists, time.Now()).sections(60) {
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Println(section.title)
+		for _, line := range section.lines {
+			fmt.Println("  " + line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestComputeStats(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	lists := []List{
+		{name: "Work", items: []Item{
+			{done: true, created: at(3, 6, 8), doneAt: at(3, 6, 10)},
+			{done: true, created: at(3, 4, 9), doneAt: at(3, 5, 9)},
+			{done: true, doneAt: at(3, 4, 12)},
+			{},
+		}},
+		{name: "Home", items: []Item{
+			{done: true, created: at(3, 1, 8), doneAt: at(3, 1, 12)},
+			// completed before completion times were recorded
+			{done: true},
+			{done: true, doneAt: at(1, 1, 12)},
+		}},
+	}
+	// A wednesday, the week started on monday the 4th.
+	s := computeStats(lists, at(3, 6, 15))
+
+	if s.done != 6 || s.total != 7 {
+		t.Errorf("done %d of %d, want 6 of 7", s.done, s.total)
+	}
+	if s.averaged != 3 || s.average != 10*time.Hour {
+		t.Errorf("average %v over %d, want 10h over 3", s.average, s.averaged)
+	}
+	if s.streak != 3 {
+		t.Errorf("streak = %d, want 3", s.streak)
+	}
+	wantDays := make([]int, statsDays)
+	wantDays[statsDays-1], wantDays[statsDays-2], wantDays[statsDays-3], wantDays[statsDays-6] = 1, 1, 1, 1
+	if fmt.Sprint(s.perDay) != fmt.Sprint(wantDays) {
+		t.Errorf("per day = %v, want %v", s.perDay, wantDays)
+	}
+	wantWeeks := make([]int, statsWeeks)
+	wantWeeks[statsWeeks-1], wantWeeks[statsWeeks-2] = 3, 1
+	if fmt.Sprint(s.perWeek) != fmt.Sprint(wantWeeks) {
+		t.Errorf("per week = %v, want %v", s.perWeek, wantWeeks)
+	}
+	if !s.firstWeek.Equal(at(1, 15, 0)) {
+		t.Errorf("first week starts %v, want monday the 15th of january", s.firstWeek)
+	}
+	if len(s.lists) != 2 || s.lists[0] != (listStats{"Work", 3, 4}) || s.lists[1] != (listStats{"Home", 3, 3}) {
+		t.Errorf("lists = %+v", s.lists)
+	}
+}
+
+func TestStatsStreak(t *testing.T) {
+	list := List{items: []Item{
+		{done: true, doneAt: time.Date(2024, 3, 4, 23, 0, 0, 0, time.Local)},
+		{done: true, doneAt: time.Date(2024, 3, 5, 1, 0, 0, 0, time.Local)},
+		{done: true, doneAt: time.Date(2024, 3, 5, 18, 0, 0, 0, time.Local)},
+	}}
+	tests := []struct {
+		now  time.Time
+		want int
+	}{
+		{time.Date(2024, 3, 5, 20, 0, 0, 0, time.Local), 2},
+		// a streak continued yesterday still counts until today ends
+		{time.Date(2024, 3, 6, 9, 0, 0, 0, time.Local), 2},
+		{time.Date(2024, 3, 7, 9, 0, 0, 0, time.Local), 0},
+		{time.Date(2024, 3, 3, 9, 0, 0, 0, time.Local), 0},
+	}
+	for _, tt := range tests {
+		if got := computeStats([]List{list}, tt.now).streak; got != tt.want {
+			t.Errorf("streak on %s = %d, want %d", tt.now.Format(dateFormat), got, tt.want)
+		}
+	}
+}
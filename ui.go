@@ -2,12 +2,13 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
-	"unicode"
+	"strings"
 
-	"github.com/gdamore/tcell"
+	"github.com/gdamore/tcell/v2"
 )
 
 type Mode int
@@ -17,6 +18,13 @@ const (
 	editMode
 	editListNameMode
 	deleteListMode
+	passphraseMode
+	helpMode
+	commandMode
+	visualMode
+	agendaMode
+	calendarMode
+	statsMode
 )
 
 const headerHeight = 6
@@ -25,19 +33,36 @@ const leftOffset = 1
 const topOffset = 1
 const bottomOffset = 1
 const navPosition = 1
+const maxLists = 9
+
+// maxSmartLists is counted apart from maxLists, smart lists come after the
+// lists the number keys switch to.
+const maxSmartLists = 9
 
 var modeTitleMap = map[Mode]string{
 	normalMode:       "Normal",
 	editListNameMode: "Insert",
 	deleteListMode:   "Delete",
 	editMode:         "Insert",
+	passphraseMode:   "Locked",
+	helpMode:         "Help",
+	commandMode:      "Command",
+	visualMode:       "Visual",
+	agendaMode:       "Agenda",
+	calendarMode:     "Calendar",
+	statsMode:        "Stats",
 }
 
-var modeStyleMap = map[Mode]tcell.Style{
-	normalMode:       lightDark,
-	editListNameMode: secondaryDark,
-	deleteListMode:   tertiaryDark,
-	editMode:         secondaryDark,
+func modeStyle(mode Mode) tcell.Style {
+	switch mode {
+	case editMode, editListNameMode, commandMode:
+		return secondaryDark
+	case deleteListMode, passphraseMode:
+		return tertiaryDark
+	case visualMode:
+		return primaryLight
+	}
+	return lightDark
 }
 
 type UI struct {
@@ -48,6 +73,29 @@ type UI struct {
 	windowTop    int
 	windowBottom int
 	mode         Mode
+	// view is the mode editing and the command line return to
+	view         Mode
+	message      string
+	messageError bool
+	passphrase   string
+	help         helpOverlay
+	command      commandLine
+	editor       lineEditor
+	killRing     []string
+	yanked       string
+	killIndex    int
+	register     register
+	bracketed    bracketedPaste
+	mouse        mouseState
+	layout       Layout
+	splitRatio   int
+	boardLeft    int
+	selection    selection
+	agenda       agenda
+	calendar     calendar
+	stats        statsView
+	history      historyCache
+	undoSteps    []undoStep
 }
 
 func newUI(debug bool) *UI {
@@ -60,6 +108,10 @@ func newUI(debug bool) *UI {
 		if err := screen.Init(); err != nil {
 			log.Fatal(err)
 		}
+		screen.EnablePaste()
+		if config.mouse {
+			screen.EnableMouse()
+		}
 		s = screen
 	}
 	db, err := newDatabase()
@@ -67,18 +119,29 @@ func newUI(debug bool) *UI {
 		log.Fatal(err)
 	}
 	db.init()
-	ui := &UI{screen: s, db: db}
+	if passphrase, ok := passphraseFromEnv(); ok {
+		db.unlock(passphrase)
+	}
+	ui := &UI{screen: s, db: db, layout: config.layout, splitRatio: config.splitRatio}
 	ui.mode = normalMode
+	applyTheme(config.theme, ui.screen.Colors())
 	ui.screen.SetStyle(darkLight)
 	return ui
 }
 
 func (ui *UI) load() {
-	lists, err := ui.db.getLists()
+	if ui.db.locked() {
+		ui.mode = passphraseMode
+		return
+	}
+	lists, err := ui.loadLists()
 	if err != nil {
 		log.Fatal(err)
 	}
-	ui.loadOrder(lists)
+	ui.lists = lists
+	for i := range ui.lists {
+		ui.lists[i].fixRow()
+	}
 	ui.calculateWindow()
 }
 
@@ -97,38 +160,76 @@ func (ui *UI) currentList() *List {
 	return &ui.lists[ui.current]
 }
 
-func (ui *UI) switchList(r rune) {
-	if !unicode.IsDigit(r) || r == '0' {
-		return
+// listCursor is the current list and the current entry of every list, saved
+// by the views over all lists to go back to them when they are closed.
+type listCursor struct {
+	list  int
+	items map[int]int
+}
+
+func (ui *UI) saveCursor() listCursor {
+	c := listCursor{list: -1, items: make(map[int]int)}
+	if l := ui.currentList(); l != nil {
+		c.list = l.ID
+	}
+	for _, l := range ui.lists {
+		if len(l.items) != 0 {
+			c.items[l.ID] = l.currentItem().id
+		}
+	}
+	return c
+}
+
+// restoreCursor goes back to the saved list and entries. Where they are gone
+// since the cursor stays, moved off hidden entries.
+func (ui *UI) restoreCursor(c listCursor) {
+	for i := range ui.lists {
+		l := &ui.lists[i]
+		if l.ID == c.list {
+			ui.current = i
+		}
+		if row := indexOf(l, c.items[l.ID]); row != -1 {
+			l.row = row
+		}
+		l.fixRow()
 	}
-	val := int(r - '0')
-	if val > len(ui.lists) {
+	ui.calculateWindow()
+}
+
+func (ui *UI) switchList(i int) {
+	if i >= len(ui.lists) {
 		return
 	}
-	ui.current = val - 1
+	ui.current = i
 	ui.calculateWindow()
 }
 
 func (ui *UI) addList() {
-	if len(ui.lists) == 9 {
+	if len(ui.realLists()) >= maxLists {
 		return
 	}
 	id, err := ui.db.createList()
 	if err != nil {
 		return
 	}
-	ui.lists = append(ui.lists, List{ID: id, name: "List name"})
-	ui.current = len(ui.lists) - 1
+	ui.current = ui.appendList(List{ID: id, name: "List name"})
+	ui.saveListOrder()
 	ui.calculateWindow()
-	ui.mode = editListNameMode
 	ui.currentList().name = " "
+	ui.enterNameEdit()
+	ui.editor.fresh = true
 }
 
 func (ui *UI) deleteList() {
 	if len(ui.lists) == 0 {
 		return
 	}
-	err := ui.db.deleteList(ui.currentList().ID)
+	var err error
+	if l := ui.currentList(); l.smart() {
+		err = ui.db.deleteSmartList(-l.ID)
+	} else {
+		err = ui.db.deleteList(l.ID)
+	}
 	if err != nil {
 		return
 	}
@@ -136,6 +237,7 @@ func (ui *UI) deleteList() {
 		ui.lists = nil
 		ui.windowBottom = 0
 		ui.windowTop = 0
+		ui.saveListOrder()
 		return
 	}
 	i := ui.current
@@ -145,55 +247,40 @@ func (ui *UI) deleteList() {
 	newLists := ui.lists[:i]
 	newLists = append(newLists, ui.lists[i+1:]...)
 	ui.lists = newLists
-	ui.calculateWindow()
+	ui.saveListOrder()
+	// The entries of the list are gone from the smart lists as well.
+	ui.changed()
 }
 
-func (ui *UI) saveOrder() {
-	err := ui.db.saveOrder(ui.lists)
-	if err != nil {
-		log.Fatal(err)
+func (ui *UI) saveItemOrder() {
+	if list := ui.currentList(); list != nil {
+		ui.saveItemOrderOf(list)
 	}
 }
 
-func (ui *UI) loadOrder(lists []List) error {
-	order, err := ui.db.loadListOrder()
-	if err != nil {
-		return err
+func (ui *UI) saveItemOrderOf(list *List) {
+	if list.smart() {
+		return
 	}
-	if order == nil || len(order) == 0 || len(order) != len(lists) {
-		ui.lists = lists
-	} else {
-		var orderedLists []List
-		for i := 0; i < len(order); i++ {
-			for j := range lists {
-				if lists[j].ID == order[i] {
-					orderedLists = append(orderedLists, lists[j])
-					continue
-				}
-			}
-		}
-		ui.lists = orderedLists
+	if conflict, _ := ui.db.saveItemOrder(list); conflict {
+		ui.notify("Entries were reordered in another instance, orders merged")
 	}
+}
 
-	for i := range ui.lists {
-		order, err := ui.db.loadItemOrder(ui.lists[i].ID)
-		if err != nil {
-			return err
-		}
-		if order == nil || len(order) == 0 || len(order) != len(ui.lists[i].items) {
-			continue
-		}
-		var orderedItems []Item
-		for j := 0; j < len(order); j++ {
-			for _, item := range ui.lists[i].items {
-				if item.id == order[j] {
-					orderedItems = append(orderedItems, item)
-				}
-			}
-		}
-		ui.lists[i].items = orderedItems
+func (ui *UI) saveListOrder() {
+	if conflict, _ := ui.db.saveListOrder(ui.realLists()); conflict {
+		ui.notify("Lists were reordered in another instance, orders merged")
 	}
-	return nil
+}
+
+func (ui *UI) notify(message string) {
+	ui.message = message
+	ui.messageError = false
+}
+
+func (ui *UI) fail(err error) {
+	ui.message = err.Error()
+	ui.messageError = true
 }
 
 func (ui *UI) handleEvent(ev tcell.Event) {
@@ -202,121 +289,136 @@ func (ui *UI) handleEvent(ev tcell.Event) {
 		ui.calculateWindow()
 		ui.screen.Sync()
 		return
+	case *tcell.EventInterrupt:
+		if _, ok := ev.Data().(dbChanged); ok {
+			ui.reload()
+		}
+		return
+	case *tcell.EventPaste:
+		ui.handlePasteEvent(ev)
+		return
+	case *tcell.EventMouse:
+		ui.message = ""
+		ui.handleMouse(ev)
+		return
 	case *tcell.EventKey:
+		if ui.bracketed.active {
+			ui.addPastedKey(ev)
+			return
+		}
 		ui.clear()
 		ui.show()
 
 		if ev.Key() == tcell.KeyCtrlC {
 			ui.exit()
 		}
+		ui.message = ""
 
 		switch ui.mode {
-		case normalMode:
-			handleNormalModeEv(ui, ev.Key(), ev.Rune())
 		case editListNameMode:
-			handleEditListNameModeEv(ui, ev.Key(), ev.Rune())
-		case deleteListMode:
-			handleDeleteListModeEv(ui, ev.Key(), ev.Rune())
+			handleEditListNameModeEv(ui, ev)
 		case editMode:
-			handleEditModeEv(ui, ev.Key(), ev.Rune())
+			handleEditModeEv(ui, ev)
+		case passphraseMode:
+			handlePassphraseModeEv(ui, ev.Key(), ev.Rune())
+		case commandMode:
+			if !runAction(ui, ev) {
+				handleCommandModeEv(ui, ev.Rune(), ev.Key() == tcell.KeyRune)
+			}
+		default:
+			runAction(ui, ev)
 		}
 	}
 }
 
-func handleNormalModeEv(ui *UI, key tcell.Key, r rune) {
-	if r == 'x' {
-		ui.exit()
-	} else if r == 'h' {
-		ui.left()
-	} else if r == 'l' {
-		ui.right()
-	} else if r == 'H' {
-		ui.switchListLeft()
-	} else if r == 'L' {
-		ui.switchListRight()
-	} else if r == 'D' {
-		ui.enterDeleteListMode()
-	} else if r == 'I' {
-		ui.enterNameEdit()
-	} else if r == 'N' {
-		ui.addList()
-	} else if r == 'j' {
-		ui.listDown()
-	} else if r == 'k' {
-		ui.listUp()
-	} else if r == 'J' {
-		ui.listSwitchDown()
-	} else if r == 'K' {
-		ui.listSwitchUp()
-	} else if r == 'n' {
-		ui.listAddEntry()
-	} else if r == 'd' {
-		ui.listDeleteEntry()
-	} else if r == 'i' {
-		ui.enterEdit()
-	} else if r == 13 {
-		ui.listMarkEntry()
-	} else {
-		ui.switchList(r)
+func handleEditListNameModeEv(ui *UI, ev *tcell.EventKey) {
+	handleEditModeEv(ui, ev)
+}
+
+func handleEditModeEv(ui *UI, ev *tcell.EventKey) {
+	if !runAction(ui, ev) && ev.Key() == tcell.KeyRune && ev.Modifiers()&tcell.ModAlt == 0 {
+		ui.editor.insert(ev.Rune())
+		ui.syncEdit()
+		ui.yanked = ""
 	}
 }
 
-func handleDeleteListModeEv(ui *UI, key tcell.Key, r rune) {
-	if r == 'y' {
-		ui.deleteList()
-		ui.mode = normalMode
-	} else if r == 'n' {
-		ui.mode = normalMode
+func (ui *UI) switchTheme() {
+	if os.Getenv("NO_COLOR") != "" {
+		ui.notify("Colors are turned off by NO_COLOR")
+		return
 	}
+	applyTheme(nextTheme(), ui.screen.Colors())
+	ui.screen.SetStyle(darkLight)
+	ui.notify("Theme: " + currentTheme.name)
+}
+
+func (ui *UI) confirmDeleteList() {
+	ui.deleteList()
+	ui.mode = normalMode
 }
 
-func handleEditListNameModeEv(ui *UI, key tcell.Key, r rune) {
-	list := ui.currentList()
-	if key == tcell.KeyEscape || key == tcell.KeyEnter {
-		ui.exitNameEdit()
+func (ui *UI) cancelDeleteList() {
+	ui.mode = normalMode
+}
+
+func handlePassphraseModeEv(ui *UI, key tcell.Key, r rune) {
+	if key == tcell.KeyEnter {
+		ui.unlock()
 	} else if r == 127 {
-		ui.currentList().deleteRuneFromName()
-	} else if key == tcell.KeyLeft {
-		list.cursorLeftListName()
-	} else if key == tcell.KeyRight {
-		list.cursorRightListName()
-	} else {
-		ui.currentList().addRuneToName(r)
+		if n := len([]rune(ui.passphrase)); n > 0 {
+			ui.passphrase = string([]rune(ui.passphrase)[:n-1])
+		}
+	} else if key == tcell.KeyRune {
+		ui.passphrase += string(r)
 	}
 }
 
-func handleEditModeEv(ui *UI, key tcell.Key, r rune) {
-	list := ui.currentList()
-	if key == tcell.KeyEscape || key == tcell.KeyEnter {
-		ui.exitEdit()
-	} else if r == 127 {
-		list.deleteRune()
-	} else if key == tcell.KeyLeft {
-		list.cursorLeftEntry()
-	} else if key == tcell.KeyRight {
-		list.cursorRightEntry()
-	} else {
-		list.addRune(r)
+func (ui *UI) unlock() {
+	err := ui.db.unlock(ui.passphrase)
+	ui.passphrase = ""
+	if err != nil {
+		ui.notify(err.Error())
+		return
 	}
+	ui.mode = normalMode
+	ui.load()
 }
 
 func (ui *UI) listDeleteEntry() {
 	if list := ui.currentList(); list != nil {
 		list.delete(ui.db, ui)
+		ui.saveItemOrder()
+		if list.smart() {
+			// The entry is gone from the list it belonged to as well.
+			ui.reload()
+		} else {
+			ui.changed()
+		}
 	}
 }
 
 func (ui *UI) listMarkEntry() {
 	if list := ui.currentList(); list != nil {
-		list.markItem(ui.db)
+		if list.markItem(ui.db) {
+			ui.notify("Entry was changed in another instance, kept your version")
+		}
+		ui.updateOwner()
+		ui.changed()
 	}
 }
 
 func (ui *UI) listAddEntry() {
 	if list := ui.currentList(); list != nil {
+		if list.smart() {
+			ui.fail(errSmartList)
+			return
+		}
 		list.add(ui.db, ui)
-		list.down(ui)
-		ui.mode = editMode
+		ui.calculateWindow()
+		ui.saveItemOrder()
+		ui.enterEdit()
+		ui.editor.fresh = true
 	}
 }
 
@@ -333,52 +435,106 @@ func (ui *UI) listUp() {
 }
 
 func (ui *UI) listSwitchDown() {
-	if list := ui.currentList(); list != nil {
+	if list := ui.currentList(); list != nil && ui.checkUnsorted() {
 		list.switchDown(ui)
+		ui.saveItemOrder()
 	}
 }
 
 func (ui *UI) listSwitchUp() {
-	if list := ui.currentList(); list != nil {
+	if list := ui.currentList(); list != nil && ui.checkUnsorted() {
 		list.switchUp(ui)
+		ui.saveItemOrder()
 	}
 }
 
 func (ui *UI) enterDeleteListMode() {
-	if len(ui.lists) != 0 {
+	if len(ui.lists) == 0 {
+		return
+	}
+	if config.confirmDeleteList {
 		ui.mode = deleteListMode
+	} else {
+		ui.deleteList()
 	}
 }
 
 func (ui *UI) render() {
-  renderListNav(ui)
-  renderCurrentList(ui)
-  renderFooter(ui)
+	if ui.mode == passphraseMode {
+		renderPassphrasePrompt(ui)
+		renderFooter(ui)
+		return
+	}
+	if ui.view == statsMode {
+		renderStats(ui)
+	} else if ui.view == calendarMode {
+		ui.syncCalendar()
+		renderCalendar(ui)
+	} else if ui.view == agendaMode {
+		ui.syncAgenda()
+		renderAgenda(ui)
+		if ui.split() {
+			renderDetails(ui)
+		}
+	} else if ui.layout == boardLayout {
+		renderBoard(ui)
+	} else {
+		ui.calculateWindow()
+		renderListNav(ui)
+		renderCurrentList(ui)
+		if ui.split() {
+			renderDetails(ui)
+		}
+	}
+	renderFooter(ui)
+	if ui.mode == helpMode {
+		renderHelp(ui)
+	}
+}
+
+func renderPassphrasePrompt(ui *UI) {
+	ui.renderLine("The data is encrypted", headerHeight-4)
+	prompt := "Passphrase: " + strings.Repeat("*", len([]rune(ui.passphrase)))
+	ui.renderLine(prompt, headerHeight-2)
 }
 
 func renderCurrentList(ui *UI) {
 	if len(ui.lists) == 0 {
-		ui.renderLine("Press N to create a new list", headerHeight-4)
+		ui.renderLine("Press "+actionKey(normalMode, "new-list")+" to create a new list", headerHeight-4)
 		return
 	}
 	ui.currentList().render(ui)
 }
 
+// navPositions returns the column the number of each list starts at in the
+// navigation.
+func (ui *UI) navPositions() []int {
+	positions := make([]int, len(ui.lists))
+	x := leftOffset
+	for i := range ui.lists {
+		positions[i] = x
+		x += len(strconv.Itoa(i+1)) + 1
+	}
+	return positions
+}
+
 func renderListNav(ui *UI) {
 	var style tcell.Style
+	positions := ui.navPositions()
 	for i := range ui.lists {
 		if i == ui.current {
 			style = lightDark
+		} else if ui.lists[i].smart() {
+			style = darkSecondary
 		} else {
 			style = darkLight
 		}
-		r := strconv.Itoa(i + 1)
-		ui.screen.SetContent(i*2+leftOffset, 1, []rune(r)[0], nil, style)
+		drawText(ui, positions[i], 1, strconv.Itoa(i+1), style)
 	}
 	if len(ui.lists) != 0 {
-		ui.screen.SetContent(ui.current*2+leftOffset, 2, '^', nil, darkLight)
+		ui.screen.SetContent(positions[ui.current], 2, '^', nil, darkLight)
 	} else {
-		renderTopSeparator(ui, separator(ui, "", 0), 5)
+		renderTopSeparator(ui, separator(ui, "", ui.width()-ui.listWidth()), 5)
 	}
 }
 
@@ -386,10 +542,10 @@ func separator(ui *UI, s string, offset int) string {
 	w := ui.width() - offset
 	var line bytes.Buffer
 	var spaceTaken int
-	if w-2 < len(s) {
+	if w-2 < textWidth(s) {
 		spaceTaken = 0
 	} else {
-		spaceTaken = len(s)
+		spaceTaken = textWidth(s)
 		line.WriteString(s)
 	}
 	for i := 0; i < w-2-spaceTaken; i++ {
@@ -419,24 +575,54 @@ func renderFooter(ui *UI) {
 	footerYPos := ui.height() - 2
 	var line string
 	if ui.mode == deleteListMode {
-		line = "Delete current list? y / n"
+		line = "Delete current list? " + actionKey(deleteListMode, "confirm") + " / " + actionKey(deleteListMode, "cancel")
 	} else if ui.mode == editListNameMode {
-		line = "List name - (esc)ape"
+		line = "List name - (" + actionKey(editListNameMode, "commit") + ") save - (" + actionKey(editListNameMode, "cancel") + ") cancel"
 	} else if ui.mode == editMode {
-		line = "Entry name - (esc)ape"
+		line = "Entry name - (" + actionKey(editMode, "commit") + ") save - (" + actionKey(editMode, "cancel") + ") cancel"
+	} else if ui.mode == passphraseMode {
+		line = "(enter) unlock - ctrl-c to quit"
+	} else if ui.mode == helpMode {
+		line = "(" + actionKey(helpMode, "close") + ") close"
+	} else if ui.mode == commandMode {
+		line = ""
+	} else if ui.mode == visualMode {
+		line = fmt.Sprintf("%d selected - (%s) toggle - (%s) delete - (%s) move", len(ui.selectedIDs()),
+			actionKey(visualMode, "toggle"), actionKey(visualMode, "delete"), actionKey(visualMode, "move"))
+	} else if ui.mode == calendarMode && ui.calendar.moving != 0 {
+		if item := ui.movingEntry(); item != nil {
+			line = "Moving \"" + item.content + "\" - (" + actionKey(calendarMode, "move") + ") drop here - (" +
+				actionKey(calendarMode, "close") + ") cancel"
+		}
+	} else if ui.mode == calendarMode {
+		line = "(" + actionKey(calendarMode, "toggle") + ") toggle - (" + actionKey(calendarMode, "move") + ") move to another day - (" +
+			actionKey(calendarMode, "close") + ") close"
+	} else if ui.mode == statsMode {
+		line = "(" + actionKey(statsMode, "close") + ") close"
+	} else if ui.mode == agendaMode {
+		line = "(" + actionKey(agendaMode, "toggle") + ") done - (" + actionKey(agendaMode, "edit") + ") edit - (" +
+			actionKey(agendaMode, "close") + ") close"
 	} else if len(ui.lists) != 0 {
-		line = "(enter) mark - e(x)it"
+		line = "(" + actionKey(normalMode, "toggle") + ") mark - (" + actionKey(normalMode, "exit") + ") exit"
+	}
+	lineStyle := primaryLight
+	if len(ui.message) != 0 {
+		line = ui.message
+		if ui.messageError {
+			lineStyle = tertiaryLight
+		}
 	}
 	modeString := padChunk(modeTitleMap[ui.mode])
 	line = separator(ui, padChunk(line), len(modeString))
-	renderChunk(ui, modeString, modeStyleMap[ui.mode], 0, footerYPos)
-	renderChunk(ui, line, primaryLight, len(modeString), footerYPos)
+	renderChunk(ui, modeString, modeStyle(ui.mode), 0, footerYPos)
+	renderChunk(ui, line, lineStyle, len(modeString), footerYPos)
+	if ui.mode == commandMode {
+		renderCommandLine(ui, len(modeString)+1, footerYPos)
+	}
 }
 
 func renderChunk(ui *UI, s string, style tcell.Style, col, row int) {
-	for i, r := range []rune(s) {
-		ui.screen.SetContent(col+i+leftOffset, row, r, nil, style)
-	}
+	drawText(ui, col+leftOffset, row, s, style)
 }
 
 func padChunk(s string) string {
@@ -444,62 +630,139 @@ func padChunk(s string) string {
 }
 
 func (ui *UI) enterEdit() {
-	if l := ui.currentList(); l != nil && len(l.items) != 0 {
-		itemLength := len(l.currentItem().content)
-		if itemLength == 1 && l.currentItem().content[0] == ' ' {
-			l.col = 0
-		} else {
-			l.col = itemLength
-		}
+	if l := ui.currentList(); l != nil && l.hasCurrent() {
+		ui.editor = newLineEditor(l.currentItem().content)
 		ui.mode = editMode
 	}
 }
 
 func (ui *UI) exitEdit() {
-	ui.mode = normalMode
-	ui.currentList().updateItem(ui.db)
-	ui.currentList().col = 0
+	ui.mode = ui.view
+	if ui.currentList().updateItem(ui.db) {
+		ui.notify("Entry was changed in another instance, kept your version")
+	}
+	ui.updateOwner()
+	ui.changed()
+}
+
+// cancelEdit restores the entry as it was before editing, a new entry is
+// removed again.
+func (ui *UI) cancelEdit() {
+	ui.mode = ui.view
+	l := ui.currentList()
+	if ui.editor.fresh {
+		l.delete(ui.db, ui)
+		ui.saveItemOrder()
+	} else {
+		l.currentItem().content = ui.editor.original
+	}
+	ui.changed()
 }
 
 func (ui *UI) enterNameEdit() {
 	if len(ui.lists) == 0 {
 		return
 	}
-	l := ui.currentList()
-	if len(l.name) == 1 && l.name[0] == ' ' {
-		l.col = 0
-	} else {
-		l.col = len(l.name)
-	}
+	ui.editor = newLineEditor(ui.currentList().name)
 	ui.mode = editListNameMode
 }
 
 func (ui *UI) exitNameEdit() {
 	ui.mode = normalMode
-	ui.currentList().updateName(ui.db)
-	ui.currentList().col = 0
+	if conflict, _ := ui.currentList().updateName(ui.db); conflict {
+		ui.notify("List name was changed in another instance, kept your version")
+	}
+	// Smart lists show the name and match it with list:.
+	ui.changed()
+}
+
+// cancelNameEdit restores the list name, a new list is removed again.
+func (ui *UI) cancelNameEdit() {
+	ui.mode = normalMode
+	if ui.editor.fresh {
+		ui.deleteList()
+		return
+	}
+	ui.currentList().name = ui.editor.original
+}
+
+// syncEdit writes the text of the editor into the entry or list name being
+// edited.
+func (ui *UI) syncEdit() {
+	l := ui.currentList()
+	switch ui.mode {
+	case editMode:
+		l.currentItem().content = ui.editor.value()
+	case editListNameMode:
+		l.name = ui.editor.value()
+	}
 }
 
 func (ui *UI) listSpaceAvailable() int {
 	return ui.height() - topOffset - headerHeight - bottomOffset - footerHeight
 }
 
+// calculateWindow sets the entries from windowTop to windowBottom that are
+// shown, keeping the current entry visible. Wrapped entries take several
+// lines, so the window is measured in lines instead of entries.
 func (ui *UI) calculateWindow() {
 	if len(ui.lists) == 0 {
 		return
 	}
-	_, h := ui.screen.Size()
-	topOffset := topOffset + headerHeight
-	bottomOffset := bottomOffset + footerHeight
-	listLength := len(ui.currentList().items)
-	spaceNeeded := listLength + topOffset + bottomOffset
-	var newWindowBottom int
-	if spaceNeeded > h {
-		newWindowBottom = max(listLength-(spaceNeeded-h), 0)
-	} else {
-		newWindowBottom = max(listLength, 0)
+	l := ui.currentList()
+	space := ui.listSpaceAvailable()
+	heights := ui.windowHeights(l)
+	row := max(l.position(l.row), 0)
+	ui.windowTop = min(ui.windowTop, row)
+	if ui.windowTop < row {
+		used := sum(heights[ui.windowTop : row+1])
+		for ui.windowTop < row && used > space {
+			used -= heights[ui.windowTop]
+			ui.windowTop++
+		}
+	}
+	below := sum(heights[ui.windowTop:])
+	for ui.windowTop > 0 && below+heights[ui.windowTop-1] <= space {
+		ui.windowTop--
+		below += heights[ui.windowTop]
+	}
+	ui.windowTop = max(ui.windowTop, 0)
+	ui.windowBottom = ui.windowTop
+	used := 0
+	for ui.windowBottom < len(heights) && (used+heights[ui.windowBottom] <= space || ui.windowBottom <= row) {
+		used += heights[ui.windowBottom]
+		ui.windowBottom++
+	}
+}
+
+// windowHeights returns the number of lines each shown entry takes, the
+// heading of the done entries counts to the entry next to it.
+func (ui *UI) windowHeights(l *List) []int {
+	visible := l.visible()
+	heights := make([]int, len(visible))
+	for p, i := range visible {
+		heights[p] = ui.rowHeight(l, i)
+	}
+	if c := l.completedAt(); c >= 0 && c < len(heights) {
+		heights[c]++
+	} else if c > 0 {
+		heights[c-1]++
 	}
-	ui.windowBottom = newWindowBottom
+	return heights
+}
+
+// rowHeight is the number of lines the entry takes. The entry being edited
+// takes one line and scrolls horizontally instead.
+func (ui *UI) rowHeight(l *List, i int) int {
+	if ui.mode == editMode && i == l.row {
+		return 1
+	}
+	return len(l.items[i].lines(ui.entryWidth()))
+}
+
+// entryWidth is the number of cells available for the content of an entry.
+func (ui *UI) entryWidth() int {
+	return max(ui.listWidth()-leftOffset-4-1, 1)
 }
 
 func (ui *UI) closeDB() {
@@ -521,7 +784,6 @@ func (ui *UI) event() tcell.Event {
 }
 
 func (ui *UI) exit() {
-	ui.saveOrder()
 	ui.screen.Fini()
 	os.Exit(0)
 }
@@ -541,17 +803,28 @@ func (ui *UI) right() {
 }
 
 func (ui *UI) switchListLeft() {
-	if ui.current == 0 {
+	if ui.layout == boardLayout {
+		ui.moveEntryLeft()
+		return
+	}
+	// Smart lists always come after the other lists.
+	if ui.current == 0 || ui.lists[ui.current].smart() {
 		return
 	}
 	ui.lists[ui.current], ui.lists[ui.current-1] = ui.lists[ui.current-1], ui.lists[ui.current]
 	ui.current--
+	ui.saveListOrder()
 }
 
 func (ui *UI) switchListRight() {
-	if ui.current == len(ui.lists)-1 {
+	if ui.layout == boardLayout {
+		ui.moveEntryRight()
+		return
+	}
+	if ui.current == len(ui.lists)-1 || ui.lists[ui.current+1].smart() {
 		return
 	}
 	ui.lists[ui.current], ui.lists[ui.current+1] = ui.lists[ui.current+1], ui.lists[ui.current]
 	ui.current++
+	ui.saveListOrder()
 }
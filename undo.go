@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+)
+
+// maxUndo is the number of steps u can go back.
+const maxUndo = 50
+
+// undoStep holds the rows of the changed entries and the entry orders of the
+// touched lists as they were before a change. The values are kept as stored,
+// sealed if the data is encrypted.
+type undoStep struct {
+	text  string
+	items []storedItem
+	// created are entries the change added, undoing it deletes them
+	created []int
+	orders  map[int]string
+}
+
+type storedItem struct {
+	id       int
+	content  string
+	done     int
+	listID   int
+	version  int
+	tags     string
+	priority int
+	notes    string
+	due      string
+	created  int64
+	doneAt   int64
+}
+
+var errNothingToUndo = errors.New("nothing to undo")
+
+// captureItems reads the entries and list orders a change is about to touch.
+func (db *DB) captureItems(ids []int, lists []*List) (undoStep, error) {
+	step := undoStep{orders: make(map[int]string)}
+	for _, id := range ids {
+		var s storedItem
+		row := db.db.QueryRow("SELECT id, content, done, list_id, version, tags, priority, notes, due, created_at, done_at FROM item WHERE id = ?", id)
+		if err := row.Scan(&s.id, &s.content, &s.done, &s.listID, &s.version, &s.tags, &s.priority, &s.notes, &s.due, &s.created, &s.doneAt); err != nil {
+			return step, err
+		}
+		step.items = append(step.items, s)
+	}
+	for _, l := range lists {
+		var ids []int
+		for _, item := range l.items {
+			ids = append(ids, item.id)
+		}
+		order, err := encodeOrder(ids)
+		if err != nil {
+			return step, err
+		}
+		step.orders[l.ID] = order
+	}
+	return step, nil
+}
+
+// restoreItems writes the captured entries back, recreating deleted ones,
+// and restores the orders in one transaction.
+func (db *DB) restoreItems(step undoStep) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range step.created {
+		if _, err := tx.Exec("DELETE FROM item WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+	for _, s := range step.items {
+		_, err := tx.Exec(`INSERT INTO item (id, content, done, list_id, version, tags, priority, notes, due, created_at, done_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET content = excluded.content, done = excluded.done, list_id = excluded.list_id,
+			version = item.version + 1, tags = excluded.tags, priority = excluded.priority, notes = excluded.notes,
+			due = excluded.due, done_at = excluded.done_at`,
+			s.id, s.content, s.done, s.listID, s.version+1, s.tags, s.priority, s.notes, s.due, s.created, s.doneAt)
+		if err != nil {
+			return err
+		}
+		if err := db.logHistory(tx, s.id, "change undone"); err != nil {
+			return err
+		}
+	}
+	for id, order := range step.orders {
+		if _, err := tx.Exec("UPDATE list SET item_order = ?, order_version = order_version + 1 WHERE id = ?", order, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// change runs f as one undoable step on the entries ids of the given lists
+// and reloads the lists afterwards.
+func (ui *UI) change(text string, ids []int, lists []*List, f func(step *undoStep) error) error {
+	step, err := ui.db.captureItems(ids, lists)
+	if err != nil {
+		return err
+	}
+	if err := f(&step); err != nil {
+		return err
+	}
+	step.text = text
+	ui.undoSteps = append(ui.undoSteps, step)
+	if len(ui.undoSteps) > maxUndo {
+		ui.undoSteps = ui.undoSteps[1:]
+	}
+	listIDs := make([]int, 0, len(lists))
+	for _, l := range lists {
+		listIDs = append(listIDs, l.ID)
+	}
+	ui.reload()
+	for _, id := range listIDs {
+		if l := ui.listByID(id); l != nil {
+			ui.saveItemOrderOf(l)
+		}
+	}
+	return nil
+}
+
+func (ui *UI) undo() {
+	if len(ui.undoSteps) == 0 {
+		ui.fail(errNothingToUndo)
+		return
+	}
+	step := ui.undoSteps[len(ui.undoSteps)-1]
+	if err := ui.db.restoreItems(step); err != nil {
+		ui.fail(err)
+		return
+	}
+	ui.undoSteps = ui.undoSteps[:len(ui.undoSteps)-1]
+	ui.reload()
+	ui.notify("Undone: " + step.text)
+}
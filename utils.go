@@ -23,7 +23,7 @@ func uiValsDebugPrint(ui *UI) {
 		return
 	}
 	w, h := ui.screen.Size()
-	line := fmt.Sprintf("Width: %d, Height: %d, Wtop: %d, Wbottom: %d, row: %d, col: %d", w, h, ui.windowTop, ui.windowBottom, ui.currentList().row, ui.currentList().col)
+	line := fmt.Sprintf("Width: %d, Height: %d, Wtop: %d, Wbottom: %d, row: %d, col: %d", w, h, ui.windowTop, ui.windowBottom, ui.currentList().row, ui.editor.cursor)
 	for col, r := range []rune(line) {
 		ui.screen.SetContent(col+1, 0, r, nil, darkLight)
 	}
@@ -35,3 +35,34 @@ func max(val1, val2 int) int {
 	}
 	return val2
 }
+
+func min(val1, val2 int) int {
+	if val1 <= val2 {
+		return val1
+	}
+	return val2
+}
+
+func sum(values []int) int {
+	var total int
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// entries returns "1 entry" or "n entries" for messages.
+func entries(n int) string {
+	if n == 1 {
+		return "1 entry"
+	}
+	return fmt.Sprintf("%d entries", n)
+}
+
+// plural returns n with word, adding an s unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
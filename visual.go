@@ -0,0 +1,315 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// selection is the state of the visual mode. Entries are selected either by
+// the range between anchor and the cursor or one by one in picked.
+type selection struct {
+	active bool
+	ranged bool
+	anchor int
+	picked map[int]bool
+}
+
+var errNoSelection = errors.New("no entries selected")
+
+func (ui *UI) enterVisual() {
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() {
+		return
+	}
+	ui.selection = selection{active: true, ranged: true, anchor: l.row, picked: make(map[int]bool)}
+	ui.mode = visualMode
+}
+
+func (ui *UI) enterVisualEntry() {
+	l := ui.currentList()
+	if l == nil || !l.hasCurrent() {
+		return
+	}
+	ui.selection = selection{active: true, picked: map[int]bool{l.currentItem().id: true}}
+	ui.mode = visualMode
+}
+
+func (ui *UI) leaveVisual() {
+	ui.selection = selection{}
+	ui.mode = normalMode
+}
+
+func (ui *UI) visualRange() {
+	if ui.selection.ranged {
+		for _, id := range ui.selectedIDs() {
+			ui.selection.picked[id] = true
+		}
+		ui.selection.ranged = false
+		return
+	}
+	ui.selection.ranged = true
+	ui.selection.anchor = ui.currentList().row
+}
+
+func (ui *UI) visualPick() {
+	id := ui.currentList().currentItem().id
+	ui.selection.picked[id] = !ui.selection.picked[id]
+}
+
+// selected tells for every entry of l whether it is selected. Hidden entries
+// never are. It is worked out for all entries at once, so rendering a list
+// does not look up the positions again for every entry.
+func (ui *UI) selected(l *List) []bool {
+	selected := make([]bool, len(l.items))
+	if !ui.selection.active || l != ui.currentList() {
+		return selected
+	}
+	s := ui.selection
+	visible := l.visible()
+	anchor, current := -1, -1
+	for p, i := range visible {
+		if i == s.anchor {
+			anchor = p
+		}
+		if i == l.row {
+			current = p
+		}
+	}
+	for p, i := range visible {
+		inRange := s.ranged && p >= min(anchor, current) && p <= max(anchor, current)
+		selected[i] = inRange || s.picked[l.items[i].id]
+	}
+	return selected
+}
+
+func (ui *UI) selectedIDs() []int {
+	var ids []int
+	if l := ui.currentList(); l != nil {
+		for i, ok := range ui.selected(l) {
+			if ok {
+				ids = append(ids, l.items[i].id)
+			}
+		}
+	}
+	return ids
+}
+
+// targetIDs returns the entries a command works on, the selection if the
+// command line was opened from the visual mode and the current entry
+// otherwise.
+func (ui *UI) targetIDs() []int {
+	if ui.selection.active {
+		return ui.selectedIDs()
+	}
+	if l := ui.currentList(); l != nil && l.hasCurrent() {
+		return []int{l.currentItem().id}
+	}
+	return nil
+}
+
+func (ui *UI) visualToggle() {
+	l := ui.currentList()
+	ids := ui.selectedIDs()
+	done := false
+	for i, ok := range ui.selected(l) {
+		if ok && !l.items[i].done {
+			done = true
+		}
+	}
+	text := fmt.Sprintf("toggle %s", entries(len(ids)))
+	err := ui.change(text, ids, nil, func(*undoStep) error {
+		return ui.db.updateItems(ids, "done", done)
+	})
+	ui.leaveVisual()
+	if err != nil {
+		ui.fail(err)
+	}
+}
+
+func (ui *UI) visualDelete() {
+	l := ui.currentList()
+	ids := ui.selectedIDs()
+	text := fmt.Sprintf("delete %s", entries(len(ids)))
+	err := ui.change(text, ids, []*List{l}, func(*undoStep) error {
+		return ui.db.deleteItems(ids)
+	})
+	ui.leaveVisual()
+	if err != nil {
+		ui.fail(err)
+	}
+}
+
+func (ui *UI) promptMove() {
+	ui.promptCommand("move ")
+}
+
+func (ui *UI) promptCopy() {
+	ui.promptCommand("copy ")
+}
+
+func (ui *UI) promptTag() {
+	ui.promptCommand("tag ")
+}
+
+func (ui *UI) promptPriority() {
+	ui.promptCommand("priority ")
+}
+
+// moveItems moves the entries to target as one undoable step and places
+// them at pos in its order.
+func (ui *UI) moveItems(ids []int, target *List, pos int) error {
+	if len(ids) == 0 {
+		return errNoSelection
+	}
+	if target.smart() {
+		return errSmartList
+	}
+	source := ui.currentList()
+	targetID := target.ID
+	text := fmt.Sprintf("move %s to %s", entries(len(ids)), target.name)
+	err := ui.change(text, ids, []*List{source, target}, func(*undoStep) error {
+		return ui.db.updateItems(ids, "list_id", targetID)
+	})
+	if err != nil {
+		return err
+	}
+	ui.placeItems(targetID, ids, pos)
+	return nil
+}
+
+// copyItems adds copies of the entries to target as one undoable step and
+// places them at pos in its order.
+func (ui *UI) copyItems(ids []int, target *List, pos int) error {
+	if len(ids) == 0 {
+		return errNoSelection
+	}
+	targetID := target.ID
+	var copies []int
+	text := fmt.Sprintf("copy %s to %s", entries(len(ids)), target.name)
+	err := ui.change(text, nil, []*List{target}, func(step *undoStep) error {
+		var err error
+		copies, err = ui.db.copyItems(ids, targetID)
+		step.created = copies
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	ui.placeItems(targetID, copies, pos)
+	return nil
+}
+
+// placeItems puts the entries ids of the list at pos in the given order,
+// pos -1 places them at the end.
+func (ui *UI) placeItems(listID int, ids []int, pos int) {
+	l := ui.listByID(listID)
+	if l == nil || pos < 0 {
+		return
+	}
+	var placed, rest []Item
+	for _, id := range ids {
+		if i := indexOf(l, id); i != -1 {
+			placed = append(placed, l.items[i])
+		}
+	}
+	for _, item := range l.items {
+		if !hasID(ids, item.id) {
+			rest = append(rest, item)
+		}
+	}
+	pos = min(pos, len(rest))
+	var current int
+	if len(l.items) != 0 {
+		current = l.currentItem().id
+	}
+	l.items = append(append(append([]Item{}, rest[:pos]...), placed...), rest[pos:]...)
+	if i := indexOf(l, current); i != -1 {
+		l.row = i
+	}
+	ui.calculateWindow()
+	ui.saveItemOrderOf(l)
+}
+
+func hasID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// cmdTag adds the tags to the entries, tags starting with - are removed.
+func (ui *UI) cmdTag(arg string) error {
+	ids := ui.targetIDs()
+	if len(ids) == 0 {
+		return errNoSelection
+	}
+	if arg == "" {
+		return errors.New("usage: tag <tag>... (-<tag> removes it)")
+	}
+	l := ui.currentList()
+	tags := make(map[int][]string)
+	for _, id := range ids {
+		tags[id] = l.items[indexOf(l, id)].tags
+		for _, tag := range strings.Fields(arg) {
+			tag = strings.TrimPrefix(tag, "#")
+			if strings.HasPrefix(tag, "-") {
+				tags[id] = removeTag(tags[id], strings.TrimPrefix(tag[1:], "#"))
+			} else if !hasTag(tags[id], tag) {
+				tags[id] = append(tags[id], tag)
+			}
+		}
+	}
+	text := fmt.Sprintf("tag %s", entries(len(ids)))
+	return ui.change(text, ids, nil, func(*undoStep) error {
+		return ui.db.setItemTags(tags)
+	})
+}
+
+func (ui *UI) cmdPriority(arg string) error {
+	ids := ui.targetIDs()
+	if len(ids) == 0 {
+		return errNoSelection
+	}
+	p, err := strconv.Atoi(arg)
+	if err != nil || p < 0 || p > maxPriority {
+		return fmt.Errorf("usage: priority <0-%d>", maxPriority)
+	}
+	text := fmt.Sprintf("set the priority of %s", entries(len(ids)))
+	return ui.change(text, ids, nil, func(*undoStep) error {
+		return ui.db.updateItems(ids, "priority", p)
+	})
+}
+
+const maxPriority = 3
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func removeTag(tags []string, tag string) []string {
+	var kept []string
+	for _, t := range tags {
+		if t != tag {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func indexOf(l *List, id int) int {
+	for i := range l.items {
+		if l.items[i].id == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// dbChanged is posted into the event loop when another process committed
+// to the database.
+type dbChanged struct{}
+
+func (ui *UI) watch() {
+	if !config.liveRefresh {
+		return
+	}
+	version, err := ui.db.dataVersion()
+	if err != nil {
+		return
+	}
+	go func() {
+		for range time.Tick(config.refreshInterval) {
+			v, err := ui.db.dataVersion()
+			if err != nil || v == version {
+				continue
+			}
+			version = v
+			ui.screen.PostEvent(tcell.NewEventInterrupt(dbChanged{}))
+		}
+	}()
+}
+
+// reload reads all lists from the database again while keeping the current
+// list, the selected entry and any edit in progress.
+func (ui *UI) reload() {
+	lists, err := ui.loadLists()
+	if err != nil {
+		return
+	}
+	defer ui.changed()
+
+	listID, itemID := -1, -1
+	var row, version int
+	var editing string
+	if l := ui.currentList(); l != nil {
+		listID = l.ID
+		row = l.row
+		if len(l.items) != 0 {
+			itemID = l.currentItem().id
+		}
+		switch ui.mode {
+		case editMode:
+			editing = l.currentItem().content
+			version = l.currentItem().version
+		case editListNameMode:
+			editing = l.name
+			version = l.version
+		}
+	}
+
+	for i := range lists {
+		if old := ui.listByID(lists[i].ID); old != nil {
+			lists[i].row = old.row
+		}
+		lists[i].fixRow()
+	}
+	ui.lists = lists
+
+	ui.current = 0
+	for i := range ui.lists {
+		if ui.lists[i].ID == listID {
+			ui.current = i
+		}
+	}
+	l := ui.currentList()
+	if l == nil || l.ID != listID {
+		ui.mode = ui.view
+		ui.windowTop = 0
+		ui.calculateWindow()
+		return
+	}
+
+	l.row = min(row, max(len(l.items)-1, 0))
+	for i := range l.items {
+		if l.items[i].id == itemID {
+			l.row = i
+		}
+	}
+	l.fixRow()
+	switch ui.mode {
+	case editMode:
+		if len(l.items) == 0 || l.currentItem().id != itemID {
+			ui.mode = ui.view
+			break
+		}
+		// Keeping the version of the edit makes exitEdit notice the
+		// concurrent change.
+		l.currentItem().content = editing
+		l.currentItem().version = version
+	case editListNameMode:
+		l.name = editing
+		l.version = version
+	}
+	ui.calculateWindow()
+}
+
+func (ui *UI) listByID(id int) *List {
+	for i := range ui.lists {
+		if ui.lists[i].ID == id {
+			return &ui.lists[i]
+		}
+	}
+	return nil
+}